@@ -0,0 +1,115 @@
+package art
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+// Op is a write of a batch, it stores Value for Key unless Delete is set.
+type Op[T any] struct {
+	Key    Key
+	Value  T
+	Delete bool
+}
+
+// Result is the outcome of an Op: the value of the key before the op, and whether the key was present.
+type Result[T any] struct {
+	Previous T
+	Loaded   bool
+}
+
+// ApplyBatch applies ops in order and returns the result of every op.
+//
+// Each op is atomic on its own, exactly like Insert and Remove, readers may see a
+// batch partially applied. The node holding the key of an op stays write locked for
+// the next ops as long as their keys belong to it, so a sorted batch locks the node
+// of a run of neighbouring keys once, and descends from the root only to the next run.
+// A snapshot sees the ops applied before it, the node held is released once a snapshot freezes it.
+func (t *Tree[T]) ApplyBatch(ops []Op[T]) []Result[T] {
+	results := make([]Result[T], len(ops))
+	var (
+		held  *inner[T]
+		path  Key
+		delta int
+	)
+	release := func() {
+		if held != nil {
+			held.lock.Unlock()
+			held = nil
+		}
+		atomic.AddInt64(&t.size, int64(delta))
+		delta = 0
+	}
+	for i := range ops {
+		op, result := &ops[i], &results[i]
+		fn := t.watch(op.Key, func(old *leaf[T]) (T, Action) {
+			if old != nil {
+				result.Previous, result.Loaded = old.value, true
+			}
+			if op.Delete {
+				return op.Value, Delete
+			}
+			return op.Value, Store
+		})
+		if held != nil && !held.epoch.isFrozen() && bytes.HasPrefix(op.Key, path) {
+			if d, ok := held.applyLocked(op.Key, len(path), op.Delete, fn); ok {
+				delta += d
+				continue
+			}
+		}
+		release()
+		if n, depth := t.lockTarget(op.Key); n != nil {
+			if d, ok := n.applyLocked(op.Key, depth, op.Delete, fn); ok {
+				held, path, delta = n, op.Key[:depth], d
+				continue
+			}
+			n.lock.Unlock()
+		}
+		// the op needs more than a single node, or the root
+		t.compute(op.Key, fn)
+	}
+	release()
+	return results
+}
+
+// lockTarget write locks the inner node which holds the leaf of key, or where it is missing, and
+// returns it with the number of bytes of key above it. The node is nil if the key isn't in an
+// inner node, or if the node is shared with a snapshot.
+func (t *Tree[T]) lockTarget(key Key) (n *inner[T], depth int) {
+restart:
+	for {
+		version, _ := t.lock.RLock()
+		root, ok := t.root.(*inner[T])
+		if !ok || root.epoch != t.epoch {
+			// compute updates the root, and copies it
+			return nil, 0
+		}
+		n, depth = root, 0
+		parent, parentVersion := &t.lock, version
+		for {
+			nodeVersion, obsolete := n.lock.RLock()
+			if obsolete || parent.RUnlock(parentVersion, nil) {
+				continue restart
+			}
+			nextDepth := depth + n.prefixLen
+			if n.prefixMismatch(key, depth) >= n.prefixLen && len(key) > nextDepth {
+				_, next := n.node.child(key[nextDepth])
+				if child, ok := next.(*inner[T]); ok {
+					if n.lock.Check(nodeVersion) {
+						continue restart
+					}
+					if _, frozen := n.frozen(child); frozen {
+						return nil, 0
+					}
+					parent, parentVersion = &n.lock, nodeVersion
+					n, depth = child, nextDepth+1
+					continue
+				}
+			}
+			if retry, restart := n.upgrade(nodeVersion, parent, parentVersion, false); retry || restart {
+				continue restart
+			}
+			return n, depth
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree_ApplyBatch(t *testing.T) {
+	tree := Tree[int]{}
+	results := tree.ApplyBatch([]Op[int]{
+		{Key: Key("a"), Value: 1},
+		{Key: Key("ab"), Value: 2},
+		{Key: Key("a"), Value: 3},
+		{Key: Key("b"), Delete: true},
+		{Key: Key("ab"), Delete: true},
+	})
+	assert.Equal(t, []Result[int]{
+		{},
+		{},
+		{Previous: 1, Loaded: true},
+		{},
+		{Previous: 2, Loaded: true},
+	}, results)
+	assert.Equal(t, 1, tree.Len())
+	value, found := tree.Search(Key("a"))
+	assert.True(t, found)
+	assert.Equal(t, 3, value)
+
+	assert.Empty(t, tree.ApplyBatch(nil))
+}
+
+func TestTree_ApplyBatchProperty(t *testing.T) {
+	alphabet := []byte{0x00, 0x01, 0x02, 0x10, 0x7f, 0xff}
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		tree := Tree[int]{}
+		ref := map[string]int{}
+		for i := 0; i < 20; i++ {
+			ops := make([]Op[int], rng.Intn(100))
+			for j := range ops {
+				ops[j] = Op[int]{Key: randomBinaryKey(rng, alphabet), Value: i*100 + j, Delete: rng.Intn(3) == 0}
+			}
+			if rng.Intn(2) == 0 {
+				sort.Slice(ops, func(a, b int) bool {
+					return string(ops[a].Key) < string(ops[b].Key)
+				})
+			}
+			results := tree.ApplyBatch(ops)
+			require.Len(t, results, len(ops))
+			for j, op := range ops {
+				previous, loaded := ref[string(op.Key)]
+				require.Equalf(t, Result[int]{Previous: previous, Loaded: loaded}, results[j], "seed %d, op %q", seed, op.Key)
+				if op.Delete {
+					delete(ref, string(op.Key))
+				} else {
+					ref[string(op.Key)] = op.Value
+				}
+			}
+			require.Equal(t, len(ref), tree.Len())
+		}
+		for key, value := range ref {
+			got, found := tree.Search(Key(key))
+			require.Truef(t, found, "seed %d, search %q", seed, key)
+			require.Equal(t, value, got)
+		}
+	}
+}
+
+func TestTree_ConcurrentApplyBatch(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 8
+		batches = 100
+		size    = 50
+	)
+	tree := Tree[int]{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				ops := make([]Op[int], 0, 2*size)
+				for k := 0; k < size; k++ {
+					ops = append(ops, Op[int]{Key: Key(fmt.Sprintf("batch::%d::%05d", w, b*size+k)), Value: k})
+				}
+				// the keys of the previous batch are deleted by the next one
+				if b > 0 {
+					for k := 0; k < size; k++ {
+						ops = append(ops, Op[int]{Key: Key(fmt.Sprintf("batch::%d::%05d", w, (b-1)*size+k)), Delete: true})
+					}
+				}
+				for i, result := range tree.ApplyBatch(ops) {
+					if i < size {
+						assert.Equal(t, Result[int]{}, result)
+					} else {
+						assert.Equal(t, Result[int]{Previous: i - size, Loaded: true}, result)
+					}
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < batches*size; i++ {
+				key := Key(fmt.Sprintf("single::%d::%05d", w, i))
+				tree.Insert(key, i)
+				if i%2 == 0 {
+					tree.Remove(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Equal(t, workers*(size+batches*size/2), tree.Len())
+	for w := 0; w < workers; w++ {
+		for k := 0; k < size; k++ {
+			value, found := tree.Search(Key(fmt.Sprintf("batch::%d::%05d", w, (batches-1)*size+k)))
+			require.True(t, found)
+			require.Equal(t, k, value)
+		}
+	}
+}
+
+func TestTree_ApplyBatchSnapshot(t *testing.T) {
+	t.Parallel()
+	const size = 100
+	tree := Tree[int]{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for b := 1; b <= 100; b++ {
+			ops := make([]Op[int], size)
+			for k := range ops {
+				ops[k] = Op[int]{Key: Key(fmt.Sprintf("key::%03d", k)), Value: b}
+			}
+			tree.ApplyBatch(ops)
+		}
+	}()
+	// a snapshot sees the ops of a batch applied before it, the first keys
+	// have the value of the batch and the others the one of the batch before
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		snapshot := tree.Snapshot()
+		var values []int
+		for iter := snapshot.Iterator(nil, nil); iter.Next(); {
+			values = append(values, iter.Value())
+		}
+		require.Equal(t, len(values), snapshot.Len())
+		if len(values) == 0 {
+			continue
+		}
+		require.True(t, sort.SliceIsSorted(values, func(i, j int) bool { return values[i] > values[j] }))
+		require.LessOrEqual(t, values[0]-values[len(values)-1], 1)
+		if len(values) < size {
+			// the first batch
+			require.Equal(t, 1, values[0])
+		}
+	}
+}
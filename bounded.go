@@ -0,0 +1,299 @@
+package art
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"unsafe"
+)
+
+// EvictionPolicy chooses the keys evicted from a BoundedTree. Its methods are
+// called with the lock of the tree held, they don't need to be thread-safe.
+type EvictionPolicy interface {
+	// Insert records a key added to the tree.
+	Insert(key Key)
+	// Access records a key read or updated.
+	Access(key Key)
+	// Remove records a key removed from the tree, evicted or not.
+	Remove(key Key)
+	// Victim returns the key to evict from the tree, which isn't empty.
+	// smallest returns the smallest key of the tree, which is evicted instead of a key missing from the tree.
+	Victim(smallest func() Key) Key
+}
+
+// BoundedOptions configures a BoundedTree, a zero limit is no limit.
+type BoundedOptions[T any] struct {
+	MaxEntries int
+	// MaxBytes limits the sum of the sizes of the entries.
+	MaxBytes int64
+	// Size estimates the bytes used by an entry, the key and the leaf holding it by default.
+	Size func(key Key, value T) int64
+	// Policy chooses the evicted keys, LRU by default.
+	Policy EvictionPolicy
+	// OnEvict is called with every evicted entry, after the write which evicted it returns.
+	OnEvict func(key Key, value T)
+}
+
+// BoundedTree is a Tree limited in entries or in bytes, the entries are evicted
+// by a policy once the limit is reached. The writes are serialized by the policy,
+// the reads of a policy tracking accesses too.
+type BoundedTree[T any] struct {
+	tree Tree[T]
+	opts BoundedOptions[T]
+
+	mu    sync.Mutex
+	bytes int64
+	// tracked is false if Access of the policy does nothing, the reads don't take mu then
+	tracked bool
+}
+
+type eviction[T any] struct {
+	key   Key
+	value T
+}
+
+// NewBoundedTree returns an empty tree limited by opts.
+func NewBoundedTree[T any](opts BoundedOptions[T]) *BoundedTree[T] {
+	if opts.Size == nil {
+		opts.Size = func(key Key, _ T) int64 {
+			return int64(len(key)) + int64(unsafe.Sizeof(leaf[T]{}))
+		}
+	}
+	if opts.Policy == nil {
+		opts.Policy = NewLRU()
+	}
+	_, untracked := opts.Policy.(untracked)
+	return &BoundedTree[T]{opts: opts, tracked: !untracked}
+}
+
+// Insert stores value for key, then evicts entries until the tree is within its limits.
+// The inserted entry itself may be evicted, if the policy chooses it.
+func (b *BoundedTree[T]) Insert(key Key, value T) (updated bool) {
+	b.mu.Lock()
+	b.tree.Compute(key, func(old T, exists bool) (T, Action) {
+		if updated = exists; updated {
+			b.bytes -= b.opts.Size(key, old)
+		}
+		return value, Store
+	})
+	b.bytes += b.opts.Size(key, value)
+	if updated {
+		b.opts.Policy.Access(key)
+	} else {
+		b.opts.Policy.Insert(key)
+	}
+	evicted := b.evict()
+	b.mu.Unlock()
+
+	if b.opts.OnEvict != nil {
+		for _, e := range evicted {
+			b.opts.OnEvict(e.key, e.value)
+		}
+	}
+	return updated
+}
+
+func (b *BoundedTree[T]) full() bool {
+	return (b.opts.MaxEntries > 0 && b.tree.Len() > b.opts.MaxEntries) ||
+		(b.opts.MaxBytes > 0 && b.bytes > b.opts.MaxBytes)
+}
+
+func (b *BoundedTree[T]) evict() (evicted []eviction[T]) {
+	smallest := func() Key {
+		c := b.tree.Cursor()
+		c.First()
+		return c.Key()
+	}
+	for b.full() && b.tree.Len() > 0 {
+		key := b.opts.Policy.Victim(smallest)
+		deleted, value := b.remove(key)
+		if !deleted {
+			// the policy chose a key which isn't in the tree, it forgets it and the smallest key is evicted instead
+			b.opts.Policy.Remove(key)
+			key = smallest()
+			if deleted, value = b.remove(key); !deleted {
+				break
+			}
+		}
+		evicted = append(evicted, eviction[T]{key: key, value: value})
+	}
+	return evicted
+}
+
+func (b *BoundedTree[T]) remove(key Key) (deleted bool, value T) {
+	if deleted, value = b.tree.Remove(key); deleted {
+		b.bytes -= b.opts.Size(key, value)
+		b.opts.Policy.Remove(key)
+	}
+	return deleted, value
+}
+
+// Search returns the value of key, the access is recorded by the policy.
+func (b *BoundedTree[T]) Search(key Key) (value T, found bool) {
+	if !b.tracked {
+		return b.tree.Search(key)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if value, found = b.tree.Search(key); found {
+		b.opts.Policy.Access(key)
+	}
+	return value, found
+}
+
+// Remove removes key and returns its value, OnEvict isn't called.
+func (b *BoundedTree[T]) Remove(key Key) (deleted bool, value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remove(key)
+}
+
+// Len returns the number of entries in the tree.
+func (b *BoundedTree[T]) Len() int {
+	return b.tree.Len()
+}
+
+// Bytes returns the sum of the sizes of the entries in the tree.
+func (b *BoundedTree[T]) Bytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
+type lru struct {
+	order *list.List // the least recently used key first
+	keys  map[string]*list.Element
+}
+
+// NewLRU returns a policy evicting the least recently used key.
+func NewLRU() EvictionPolicy {
+	return &lru{order: list.New(), keys: map[string]*list.Element{}}
+}
+
+func (p *lru) Insert(key Key) {
+	p.keys[string(key)] = p.order.PushBack(key)
+}
+
+func (p *lru) Access(key Key) {
+	if e, ok := p.keys[string(key)]; ok {
+		p.order.MoveToBack(e)
+	}
+}
+
+func (p *lru) Remove(key Key) {
+	if e, ok := p.keys[string(key)]; ok {
+		p.order.Remove(e)
+		delete(p.keys, string(key))
+	}
+}
+
+func (p *lru) Victim(func() Key) Key {
+	return p.order.Front().Value.(Key)
+}
+
+type lfuEntry struct {
+	key   Key
+	count uint64
+	// last is the time of the last access, the least recently used goes first among the equally used
+	last  uint64
+	index int
+}
+
+type lfu struct {
+	entries lfuHeap
+	keys    map[string]*lfuEntry
+	clock   uint64
+	// latest is the entry inserted or accessed last
+	latest *lfuEntry
+}
+
+// NewLFU returns a policy evicting the least frequently used key, or the least recently
+// used one among the equally used keys. The key used last is never evicted, unless it's
+// the only one, otherwise a new key would be evicted by its own insertion.
+func NewLFU() EvictionPolicy {
+	return &lfu{keys: map[string]*lfuEntry{}}
+}
+
+func (p *lfu) Insert(key Key) {
+	p.clock++
+	e := &lfuEntry{key: key, count: 1, last: p.clock}
+	p.keys[string(key)] = e
+	p.latest = e
+	heap.Push(&p.entries, e)
+}
+
+func (p *lfu) Access(key Key) {
+	if e, ok := p.keys[string(key)]; ok {
+		p.clock++
+		e.count++
+		e.last = p.clock
+		p.latest = e
+		heap.Fix(&p.entries, e.index)
+	}
+}
+
+func (p *lfu) Remove(key Key) {
+	if e, ok := p.keys[string(key)]; ok {
+		heap.Remove(&p.entries, e.index)
+		delete(p.keys, string(key))
+		if p.latest == e {
+			p.latest = nil
+		}
+	}
+}
+
+func (p *lfu) Victim(func() Key) Key {
+	if p.entries[0] != p.latest || len(p.entries) == 1 {
+		return p.entries[0].key
+	}
+	// the next one is a child of the root
+	next := 1
+	if len(p.entries) > 2 && p.entries.Less(2, 1) {
+		next = 2
+	}
+	return p.entries[next].key
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].last < h[j].last
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *lfuHeap) Push(x any) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// untracked is implemented by the policies which don't track accesses.
+type untracked interface {
+	untracked()
+}
+
+type smallestKey struct{}
+
+// SmallestKey returns a policy evicting the smallest key of the tree, which keeps
+// a sliding window over the largest keys, like the latest timestamps.
+func SmallestKey() EvictionPolicy {
+	return smallestKey{}
+}
+
+func (smallestKey) Insert(Key)                     {}
+func (smallestKey) Access(Key)                     {}
+func (smallestKey) Remove(Key)                     {}
+func (smallestKey) Victim(smallest func() Key) Key { return smallest() }
+func (smallestKey) untracked()                     {}
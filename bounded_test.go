@@ -0,0 +1,182 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundedTree_LRU(t *testing.T) {
+	var evicted []string
+	tree := NewBoundedTree(BoundedOptions[int]{
+		MaxEntries: 3,
+		OnEvict: func(key Key, value int) {
+			evicted = append(evicted, fmt.Sprintf("%s=%d", key, value))
+		},
+	})
+	tree.Insert(Key("a"), 1)
+	tree.Insert(Key("b"), 2)
+	tree.Insert(Key("c"), 3)
+	tree.Search(Key("a"))
+	assert.True(t, tree.Insert(Key("b"), 4))
+	tree.Insert(Key("d"), 5)
+	assert.Equal(t, []string{"c=3"}, evicted)
+	tree.Insert(Key("e"), 6)
+	assert.Equal(t, []string{"c=3", "a=1"}, evicted)
+	assert.Equal(t, 3, tree.Len())
+
+	// removing isn't evicting
+	deleted, value := tree.Remove(Key("b"))
+	assert.True(t, deleted)
+	assert.Equal(t, 4, value)
+	tree.Insert(Key("f"), 7)
+	assert.Equal(t, []string{"c=3", "a=1"}, evicted)
+}
+
+func TestBoundedTree_LFU(t *testing.T) {
+	var evicted []string
+	tree := NewBoundedTree(BoundedOptions[int]{
+		MaxEntries: 3,
+		Policy:     NewLFU(),
+		OnEvict: func(key Key, value int) {
+			evicted = append(evicted, string(key))
+		},
+	})
+	tree.Insert(Key("a"), 1)
+	tree.Insert(Key("b"), 2)
+	tree.Insert(Key("c"), 3)
+	tree.Search(Key("a"))
+	tree.Search(Key("a"))
+	tree.Search(Key("b"))
+	tree.Search(Key("c"))
+	// b and c are used as often, b is the least recently used of them
+	tree.Insert(Key("d"), 4)
+	assert.Equal(t, []string{"b"}, evicted)
+	tree.Insert(Key("e"), 5)
+	assert.Equal(t, []string{"b", "d"}, evicted)
+}
+
+func TestBoundedTree_SmallestKey(t *testing.T) {
+	tree := NewBoundedTree(BoundedOptions[int]{MaxEntries: 10, Policy: SmallestKey()})
+	for i := 0; i < 100; i++ {
+		tree.Insert(Key(fmt.Sprintf("ts::%03d", rand.Intn(100)+i)), i)
+	}
+	require.Equal(t, 10, tree.Len())
+	// the window holds the largest keys
+	var keys []string
+	for iter := tree.tree.Iterator(nil, nil); iter.Next(); {
+		keys = append(keys, string(iter.Key()))
+	}
+	for _, key := range keys {
+		assert.GreaterOrEqual(t, key, "ts::099")
+	}
+
+	// the policy doesn't track accesses, the reads don't wait for the writes
+	tree.mu.Lock()
+	_, found := tree.Search(Key(keys[0]))
+	tree.mu.Unlock()
+	assert.True(t, found)
+	assert.True(t, NewBoundedTree(BoundedOptions[int]{}).tracked)
+}
+
+func TestBoundedTree_MaxBytes(t *testing.T) {
+	var evicted int
+	tree := NewBoundedTree(BoundedOptions[[]byte]{
+		MaxBytes: 100,
+		Size: func(key Key, value []byte) int64 {
+			return int64(len(key) + len(value))
+		},
+		OnEvict: func(Key, []byte) { evicted++ },
+	})
+	for i := 0; i < 20; i++ {
+		tree.Insert(Key(fmt.Sprintf("%02d", i)), make([]byte, 8))
+		assert.LessOrEqual(t, tree.Bytes(), int64(100))
+	}
+	assert.Equal(t, 10, tree.Len())
+	assert.Equal(t, int64(100), tree.Bytes())
+	assert.Equal(t, 10, evicted)
+
+	// an update changes the size of the entry
+	tree.Insert(Key("19"), make([]byte, 28))
+	assert.Equal(t, int64(100), tree.Bytes())
+	assert.Equal(t, 8, tree.Len())
+	// an entry larger than the limit doesn't stay
+	tree.Insert(Key("large"), make([]byte, 200))
+	assert.Equal(t, 0, tree.Len())
+	assert.Equal(t, int64(0), tree.Bytes())
+}
+
+// stalePolicy always chooses a key which isn't in the tree.
+type stalePolicy struct{ smallestKey }
+
+func (stalePolicy) Victim(func() Key) Key { return Key("missing") }
+
+func TestBoundedTree_StaleVictim(t *testing.T) {
+	var evicted []string
+	tree := NewBoundedTree(BoundedOptions[int]{
+		MaxEntries: 2,
+		Policy:     stalePolicy{},
+		OnEvict:    func(key Key, _ int) { evicted = append(evicted, string(key)) },
+	})
+	for _, key := range []string{"b", "c", "a"} {
+		tree.Insert(Key(key), 0)
+	}
+	// the smallest key is evicted instead of the missing one
+	assert.Equal(t, 2, tree.Len())
+	assert.Equal(t, []string{"a"}, evicted)
+}
+
+func TestBoundedTree_ConcurrentInsert(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []func() EvictionPolicy{NewLRU, NewLFU, SmallestKey} {
+		const (
+			N          = 30_000
+			maxEntries = 1000
+		)
+		var (
+			mu      sync.Mutex
+			evicted = map[string]bool{}
+		)
+		tree := NewBoundedTree(BoundedOptions[[]byte]{
+			MaxEntries: maxEntries,
+			Policy:     policy(),
+			OnEvict: func(key Key, value []byte) {
+				mu.Lock()
+				defer mu.Unlock()
+				evicted[string(key)] = true
+			},
+		})
+		inserted := make([]Key, N)
+		wg := sync.WaitGroup{}
+		for i := 0; i < N; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+				k := randomKey(rng)
+				tree.Insert(k, k)
+				tree.Search(k)
+				inserted[i] = k
+			}(i)
+		}
+		wg.Wait()
+
+		require.Equal(t, maxEntries, tree.Len())
+		// every key is either in the tree or evicted
+		present := 0
+		for _, key := range inserted {
+			if value, found := tree.tree.Search(key); found {
+				assert.Equal(t, []byte(key), value)
+				present++
+			} else {
+				assert.True(t, evicted[string(key)])
+			}
+		}
+		assert.Equal(t, maxEntries, present)
+	}
+}
@@ -0,0 +1,86 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// ErrUnsorted is returned by BuildFromSorted when a key is less than the key before it.
+	ErrUnsorted = errors.New("art: keys are not sorted")
+	// ErrDuplicateKey is returned by BuildFromSorted when a key is equal to the key before it.
+	ErrDuplicateKey = errors.New("art: duplicate key")
+)
+
+// BuildFromSorted returns a tree of the keys and values returned by iter, until it returns false.
+// The keys must be in strictly increasing lexicographic order.
+//
+// Every inner node is created once, at its final size and with its final prefix,
+// instead of growing it key by key like Insert does.
+func BuildFromSorted[T any](iter func() (Key, T, bool)) (*Tree[T], error) {
+	var leaves []*leaf[T]
+	for {
+		key, value, ok := iter()
+		if !ok {
+			break
+		}
+		if len(leaves) > 0 {
+			switch bytes.Compare(leaves[len(leaves)-1].key, key) {
+			case 0:
+				return nil, ErrDuplicateKey
+			case 1:
+				return nil, ErrUnsorted
+			}
+		}
+		leaves = append(leaves, &leaf[T]{key: key, value: value})
+	}
+	t := &Tree[T]{size: int64(len(leaves))}
+	if len(leaves) > 0 {
+		t.root = build(leaves, 0, t.current())
+	}
+	return t, nil
+}
+
+// build returns the node of the sorted leaves, which share the first depth bytes of their keys.
+// The inner nodes belong to epoch.
+func build[T any](leaves []*leaf[T], depth int, epoch *generation) node[T] {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	// the keys are sorted, the prefix shared by the first and the last one is shared by all of them
+	first, last := leaves[0], leaves[len(leaves)-1]
+	prefixLen := comparePrefix(first.key, last.key, depth)
+	n := &inner[T]{epoch: epoch}
+	n.setPrefix(first.key[depth:], prefixLen)
+	depth += prefixLen
+	if len(first.key) == depth {
+		n.leaf = first
+		leaves = leaves[1:]
+	}
+
+	children := 0
+	for i := range leaves {
+		if i == 0 || leaves[i].key[depth] != leaves[i-1].key[depth] {
+			children++
+		}
+	}
+	switch {
+	case children <= 4:
+		n.node = &node4[T]{}
+	case children <= 16:
+		n.node = &node16[T]{}
+	case children <= 48:
+		n.node = &node48[T]{}
+	default:
+		n.node = &node256[T]{}
+	}
+	for start := 0; start < len(leaves); {
+		end := start + 1
+		for end < len(leaves) && leaves[end].key[depth] == leaves[start].key[depth] {
+			end++
+		}
+		n.node.addChild(leaves[start].key[depth], build(leaves[start:end], depth+1, epoch))
+		start = end
+	}
+	return n
+}
@@ -0,0 +1,143 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sorted returns an iterator of keys, the value of a key is its index.
+func sorted(keys ...string) func() (Key, int, bool) {
+	i := 0
+	return func() (Key, int, bool) {
+		if i == len(keys) {
+			return nil, 0, false
+		}
+		i++
+		return Key(keys[i-1]), i - 1, true
+	}
+}
+
+func TestBuildFromSorted(t *testing.T) {
+	tree, err := BuildFromSorted(sorted())
+	require.NoError(t, err)
+	assert.Equal(t, 0, tree.Len())
+	tree.Insert(Key("a"), 1)
+	assert.Equal(t, 1, tree.Len())
+
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "this_is_a_long_prefix::1", "this_is_a_long_prefix::2"}
+	tree, err = BuildFromSorted(sorted(keys...))
+	require.NoError(t, err)
+	assert.Equal(t, len(keys), tree.Len())
+	for i, key := range keys {
+		value, found := tree.Search(Key(key))
+		require.Truef(t, found, "search %q", key)
+		assert.Equal(t, i, value)
+	}
+	var iterated []string
+	for iter := tree.Iterator(nil, nil); iter.Next(); {
+		iterated = append(iterated, string(iter.Key()))
+	}
+	assert.Equal(t, keys, iterated)
+	assert.Equal(t, 2, tree.DeletePrefix(Key("this")))
+	assert.False(t, tree.Insert(Key("abe"), 8))
+	assert.Equal(t, 7, tree.Len())
+
+	_, err = BuildFromSorted(sorted("a", "c", "b"))
+	assert.ErrorIs(t, err, ErrUnsorted)
+	_, err = BuildFromSorted(sorted("a", "b", "b"))
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+}
+
+func TestBuildFromSorted_NodeKinds(t *testing.T) {
+	for _, tc := range []struct {
+		children int
+		kind     Kind
+	}{
+		{children: 2, kind: Node4},
+		{children: 5, kind: Node16},
+		{children: 17, kind: Node48},
+		{children: 49, kind: Node256},
+		{children: 256, kind: Node256},
+	} {
+		keys := make([]string, tc.children)
+		for i := range keys {
+			keys[i] = string([]byte{'k', byte(i)})
+		}
+		tree, err := BuildFromSorted(sorted(keys...))
+		require.NoError(t, err)
+		var root NodeInfo
+		tree.Walk(func(info NodeInfo) bool {
+			root = info
+			return false
+		})
+		assert.Equal(t, NodeInfo{Kind: tc.kind, Prefix: []byte("k"), PrefixLen: 1, Children: tc.children}, root)
+	}
+}
+
+// The tree built from sorted keys is the same as the tree of the keys inserted one by one.
+func TestBuildFromSortedProperty(t *testing.T) {
+	alphabet := []byte{0x00, 0x01, 0x02, 0x10, 0x7f, 0xff}
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		inserted := Tree[int]{}
+		unique := map[string]bool{}
+		for i := rng.Intn(1000); i > 0; i-- {
+			key := randomBinaryKey(rng, alphabet)
+			if i%10 == 0 {
+				// wide nodes
+				key = append(key, byte(rng.Intn(256)))
+			}
+			unique[string(key)] = true
+		}
+		keys := make([]string, 0, len(unique))
+		for key := range unique {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			inserted.Insert(Key(key), i)
+		}
+		built, err := BuildFromSorted(sorted(keys...))
+		require.NoError(t, err)
+		require.Equal(t, inserted.Len(), built.Len())
+
+		var expected, actual []NodeInfo
+		inserted.Walk(func(info NodeInfo) bool {
+			expected = append(expected, info)
+			return true
+		})
+		built.Walk(func(info NodeInfo) bool {
+			actual = append(actual, info)
+			return true
+		})
+		require.Equalf(t, expected, actual, "seed %d", seed)
+	}
+}
+
+func BenchmarkBuildFromSorted(b *testing.B) {
+	const size = 100000
+	keys := make([]string, size)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key::%08d", i)
+	}
+	b.Run("build", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := BuildFromSorted(sorted(keys...)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("insert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tree := Tree[int]{}
+			for j, key := range keys {
+				tree.Insert(Key(key), j)
+			}
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package art
+
+// ComparableTree is a Tree of comparable values, which adds the operations
+// comparing the stored values. The zero value is an empty tree ready to use.
+type ComparableTree[T comparable] struct {
+	Tree[T]
+}
+
+// CompareAndSwap stores value for key if the key is present and its value is equal to old.
+func (t *ComparableTree[T]) CompareAndSwap(key Key, old, value T) (swapped bool) {
+	t.Compute(key, func(current T, exists bool) (T, Action) {
+		if !exists || current != old {
+			return current, Keep
+		}
+		swapped = true
+		return value, Store
+	})
+	return swapped
+}
+
+// CompareAndDelete removes key if it is present and its value is equal to old.
+func (t *ComparableTree[T]) CompareAndDelete(key Key, old T) (deleted bool) {
+	t.Compute(key, func(current T, exists bool) (T, Action) {
+		if !exists || current != old {
+			return current, Keep
+		}
+		deleted = true
+		return current, Delete
+	})
+	return deleted
+}
@@ -0,0 +1,71 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparableTree(t *testing.T) {
+	tree := ComparableTree[string]{}
+	assert.False(t, tree.CompareAndSwap(Key("a"), "", "1"))
+	assert.False(t, tree.CompareAndDelete(Key("a"), ""))
+	assert.Equal(t, 0, tree.Len())
+
+	tree.Insert(Key("a"), "1")
+	tree.Insert(Key("ab"), "2")
+	assert.False(t, tree.CompareAndSwap(Key("a"), "2", "3"))
+	assert.True(t, tree.CompareAndSwap(Key("a"), "1", "3"))
+	value, _ := tree.Search(Key("a"))
+	assert.Equal(t, "3", value)
+
+	assert.False(t, tree.CompareAndDelete(Key("ab"), "1"))
+	assert.True(t, tree.CompareAndDelete(Key("ab"), "2"))
+	_, found := tree.Search(Key("ab"))
+	assert.False(t, found)
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestComparableTree_ConcurrentCompareAndSwap(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 8
+		keys    = 4
+		rounds  = 1000
+	)
+	tree := ComparableTree[int]{}
+	for k := 0; k < keys; k++ {
+		tree.Insert(Key(fmt.Sprintf("version::%d", k)), 0)
+	}
+	// every successful swap bumps the version, none of them is lost
+	swaps := make([]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := Key(fmt.Sprintf("version::%d", i%keys))
+				current, _ := tree.Search(key)
+				if tree.CompareAndSwap(key, current, current+1) {
+					swaps[w]++
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total, swapped := 0, 0
+	for k := 0; k < keys; k++ {
+		value, found := tree.Search(Key(fmt.Sprintf("version::%d", k)))
+		require.True(t, found)
+		total += value
+	}
+	for _, n := range swaps {
+		swapped += n
+	}
+	assert.Equal(t, swapped, total)
+}
@@ -0,0 +1,331 @@
+package art
+
+import (
+	"bytes"
+)
+
+// Cursor is a position in the tree which can be moved in both directions and
+// repositioned at any time. A cursor isn't safe for concurrent use, but the tree
+// can be modified concurrently: a cursor whose path has changed finds its
+// neighbour again from the root of the tree, by the key it is positioned at.
+//
+// The cursor keeps the path from the root to its key as a stack of checkpoints,
+// the checkpoint of every inner node points at the entry of the node on the path,
+// which is either the leaf of the node or one of its children.
+type Cursor[T any] struct {
+	tree     *Tree[T]
+	snapshot *Snapshot[T]
+
+	stack *checkpoint[T]
+	// prefix bounds the cursor to the keys starting with it
+	prefix []byte
+	valid  bool
+
+	key   Key
+	value T
+
+	// read is called with the version of every node read, the transactions validate them
+	read func(lock *olock, version uint64)
+}
+
+// Cursor returns a cursor over the tree, it isn't positioned until one of the seek methods is called.
+func (t *Tree[T]) Cursor() *Cursor[T] {
+	return &Cursor[T]{tree: t}
+}
+
+// Cursor returns a cursor over the snapshot.
+func (s *Snapshot[T]) Cursor() *Cursor[T] {
+	return &Cursor[T]{snapshot: s}
+}
+
+// Valid is true if the cursor is positioned at a key.
+func (c *Cursor[T]) Valid() bool {
+	return c.valid
+}
+
+func (c *Cursor[T]) Key() Key {
+	if !c.valid {
+		return nil
+	}
+	return c.key
+}
+
+func (c *Cursor[T]) Value() (value T) {
+	if !c.valid {
+		return value
+	}
+	return c.value
+}
+
+// Seek moves the cursor to the first key greater than or equal to key.
+func (c *Cursor[T]) Seek(key Key) bool {
+	c.prefix = nil
+	c.seek(key, true, true)
+	return c.valid
+}
+
+// SeekPrefix moves the cursor to the first key starting with prefix. The cursor
+// stays within the keys starting with prefix, until it is repositioned by another seek.
+func (c *Cursor[T]) SeekPrefix(prefix []byte) bool {
+	c.prefix = prefix
+	c.seek(prefix, true, true)
+	return c.valid
+}
+
+// First moves the cursor to the smallest key of the tree.
+func (c *Cursor[T]) First() bool {
+	c.prefix = nil
+	for c.edge(true) {
+	}
+	return c.valid
+}
+
+// Last moves the cursor to the largest key of the tree.
+func (c *Cursor[T]) Last() bool {
+	c.prefix = nil
+	for c.edge(false) {
+	}
+	return c.valid
+}
+
+// Next moves the cursor to the next key, it returns false and the cursor
+// becomes invalid if there is none. Next and Prev do nothing on an invalid cursor.
+func (c *Cursor[T]) Next() bool {
+	return c.move(true)
+}
+
+// Prev moves the cursor to the previous key.
+func (c *Cursor[T]) Prev() bool {
+	return c.move(false)
+}
+
+func (c *Cursor[T]) move(forward bool) bool {
+	if !c.valid {
+		return false
+	}
+	if c.stack == nil || c.advance(forward) {
+		// the path has changed, find the neighbour of the key again
+		c.seek(c.key, forward, false)
+		return c.valid
+	}
+	return c.bound()
+}
+
+// bound invalidates the cursor if it moved past the keys starting with the prefix.
+func (c *Cursor[T]) bound() bool {
+	if c.valid && !bytes.HasPrefix(c.key, c.prefix) {
+		c.valid = false
+	}
+	return c.valid
+}
+
+func (c *Cursor[T]) emit(l *leaf[T]) {
+	c.key = l.key
+	c.value = l.value
+	c.valid = true
+}
+
+// rlock and changed skip the optimistic locks when a snapshot is read, rlock
+// only waits for a writer which locked the node before the snapshot was taken.
+func (c *Cursor[T]) rlock(l *olock) (version uint64, obsolete bool) {
+	if c.snapshot != nil {
+		l.wait()
+		return 0, false
+	}
+	version, obsolete = l.RLock()
+	if c.read != nil {
+		c.read(l, version)
+	}
+	return version, obsolete
+}
+
+func (c *Cursor[T]) changed(l *olock, version uint64) bool {
+	if c.snapshot != nil {
+		return false
+	}
+	return l.RUnlock(version, nil)
+}
+
+func (c *Cursor[T]) root() (n node[T], lock *olock, version uint64, restart bool) {
+	if c.snapshot != nil {
+		return c.snapshot.root, nil, 0, false
+	}
+	version, _ = c.tree.lock.RLock()
+	if c.read != nil {
+		c.read(&c.tree.lock, version)
+	}
+	n = c.tree.root
+	return n, &c.tree.lock, version, c.tree.lock.RUnlock(version, nil)
+}
+
+// edge moves the cursor to the first key of the tree, or to the last one in reverse.
+func (c *Cursor[T]) edge(forward bool) (restart bool) {
+	c.stack, c.valid = nil, false
+	n, lock, version, restart := c.root()
+	if restart {
+		return true
+	}
+	return c.descend(n, forward, lock, version)
+}
+
+// seek moves the cursor to the first key after key, or to the last one before it in reverse.
+// key itself is included if inclusive is true.
+func (c *Cursor[T]) seek(key []byte, forward, inclusive bool) {
+	for c.trySeek(key, forward, inclusive) {
+	}
+	c.bound()
+}
+
+func (c *Cursor[T]) trySeek(key []byte, forward, inclusive bool) (restart bool) {
+	c.stack, c.valid = nil, false
+	n, parentLock, parentVersion, restart := c.root()
+	if restart {
+		return true
+	}
+	depth := 0
+	for {
+		switch current := n.(type) {
+		case *leaf[T]:
+			cmp := bytes.Compare(current.key, key)
+			if cmp == 0 && inclusive || cmp > 0 && forward || cmp < 0 && !forward {
+				c.emit(current)
+				return false
+			}
+			return c.advance(forward)
+		case *inner[T]:
+			version, obsolete := c.rlock(&current.lock)
+			if obsolete {
+				return true
+			}
+			cmp := current.comparePath(key, depth)
+			nextDepth := depth + current.prefixLen
+			l := current.leaf
+			var (
+				b     byte
+				child node[T]
+			)
+			if cmp == 0 && len(key) > nextDepth {
+				b = key[nextDepth]
+				_, child = current.node.child(b)
+			}
+			if c.changed(&current.lock, version) {
+				return true
+			}
+			if cmp < 0 && forward || cmp > 0 && !forward {
+				// all the keys of the node are after key in the direction,
+				// descend reads the node again and checks the parent
+				return c.descend(current, forward, parentLock, parentVersion)
+			}
+			if c.changed(parentLock, parentVersion) {
+				return true
+			}
+			if cmp != 0 {
+				// all the keys of the node are before key in the direction
+				return c.advance(forward)
+			}
+			tail := &checkpoint[T]{node: current, version: version, prev: c.stack}
+			c.stack = tail
+			if len(key) == nextDepth {
+				// key terminates at the node, the keys of the children are after it
+				if inclusive && l != nil {
+					tail.leaf = true
+					c.emit(l)
+					return false
+				}
+				if forward {
+					tail.leaf = true
+				} else {
+					c.stack = tail.prev
+				}
+				return c.advance(forward)
+			}
+			tail.pointer = &b
+			if child == nil {
+				return c.advance(forward)
+			}
+			n, parentLock, parentVersion = child, &current.lock, version
+			depth = nextDepth + 1
+		default:
+			return false
+		}
+	}
+}
+
+// descend moves the cursor to the first key of n, or to the last one in reverse.
+// n has been read from the node locked by parentLock at parentVersion.
+func (c *Cursor[T]) descend(n node[T], forward bool, parentLock *olock, parentVersion uint64) (restart bool) {
+	for {
+		switch current := n.(type) {
+		case *leaf[T]:
+			c.emit(current)
+			return false
+		case *inner[T]:
+			version, obsolete := c.rlock(&current.lock)
+			if obsolete || c.changed(parentLock, parentVersion) {
+				return true
+			}
+			tail := &checkpoint[T]{node: current, version: version, prev: c.stack}
+			var b byte
+			if forward && current.leaf != nil {
+				tail.leaf, n = true, current.leaf
+			} else if forward {
+				b, n = current.node.next(nil)
+			} else if b, n = current.node.prev(nil); n == nil && current.leaf != nil {
+				tail.leaf, n = true, current.leaf
+			}
+			if c.changed(&current.lock, version) {
+				return true
+			}
+			if n == nil {
+				// an inner node is never empty, unless it has been changed
+				return true
+			}
+			if !tail.leaf {
+				tail.pointer = &b
+			}
+			c.stack = tail
+			parentLock, parentVersion = &current.lock, version
+		default:
+			return false
+		}
+	}
+}
+
+// advance moves the cursor from the entry at the top of the stack to the next key,
+// or to the previous one in reverse. The cursor becomes invalid if there is none.
+func (c *Cursor[T]) advance(forward bool) (restart bool) {
+	for tail := c.stack; tail != nil; tail = c.stack {
+		n := tail.node
+		var (
+			b     byte
+			child node[T]
+			leaf  bool
+		)
+		switch {
+		case forward && tail.leaf:
+			b, child = n.node.next(nil)
+		case forward:
+			b, child = n.node.next(tail.pointer)
+		case !tail.leaf:
+			// the leaf of the node is before all of its children
+			if b, child = n.node.prev(tail.pointer); child == nil && n.leaf != nil {
+				child, leaf = n.leaf, true
+			}
+		}
+		if c.changed(&n.lock, tail.version) {
+			return true
+		}
+		if child == nil {
+			// the node is exhausted, move one level up the stack
+			c.stack = tail.prev
+			continue
+		}
+		tail.leaf, tail.pointer = leaf, nil
+		if !leaf {
+			tail.pointer = &b
+		}
+		return c.descend(child, forward, &n.lock, tail.version)
+	}
+	c.valid = false
+	return false
+}
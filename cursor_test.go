@@ -0,0 +1,215 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	tree := Tree[int]{}
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "this_is_a_long_prefix::1", "this_is_a_long_prefix::2", "z"}
+	for i, key := range keys {
+		tree.Insert(Key(key), i)
+	}
+
+	c := tree.Cursor()
+	assert.False(t, c.Valid())
+	var forward []string
+	for ok := c.First(); ok; ok = c.Next() {
+		forward = append(forward, string(c.Key()))
+		assert.Equal(t, len(forward)-1, c.Value())
+	}
+	assert.Equal(t, keys, forward)
+	assert.False(t, c.Valid())
+	assert.False(t, c.Prev())
+
+	var reverse []string
+	for ok := c.Last(); ok; ok = c.Prev() {
+		reverse = append(reverse, string(c.Key()))
+	}
+	for i, j := 0, len(reverse)-1; i < j; i, j = i+1, j-1 {
+		reverse[i], reverse[j] = reverse[j], reverse[i]
+	}
+	assert.Equal(t, keys, reverse)
+
+	for _, tc := range []struct {
+		seek  string
+		found string
+		valid bool
+	}{
+		{seek: "", found: "", valid: true},
+		{seek: "ab", found: "ab", valid: true},
+		{seek: "aba", found: "abc", valid: true},
+		{seek: "abe", found: "b", valid: true},
+		{seek: "this", found: "this_is_a_long_prefix::1", valid: true},
+		{seek: "this_is_a_long_prefix::10", found: "this_is_a_long_prefix::2", valid: true},
+		{seek: "this_is_a_long_prefix::3", found: "z", valid: true},
+		{seek: "za", valid: false},
+	} {
+		assert.Equal(t, tc.valid, c.Seek(Key(tc.seek)), tc.seek)
+		if tc.valid {
+			assert.Equal(t, tc.found, string(c.Key()), tc.seek)
+		}
+	}
+
+	// the direction is switched in place
+	require.True(t, c.Seek(Key("abc")))
+	require.True(t, c.Prev())
+	assert.Equal(t, "ab", string(c.Key()))
+	require.True(t, c.Next())
+	assert.Equal(t, "abc", string(c.Key()))
+	require.True(t, c.Next())
+	assert.Equal(t, "abd", string(c.Key()))
+
+	// the cursor stays within the prefix
+	var prefixed []string
+	for ok := c.SeekPrefix([]byte("ab")); ok; ok = c.Next() {
+		prefixed = append(prefixed, string(c.Key()))
+	}
+	assert.Equal(t, []string{"ab", "abc", "abd"}, prefixed)
+	require.True(t, c.SeekPrefix([]byte("ab")))
+	assert.False(t, c.Prev())
+	assert.False(t, c.SeekPrefix([]byte("abe")))
+	// another seek lifts the prefix
+	require.True(t, c.Seek(Key("ab")))
+	require.True(t, c.Prev())
+	assert.Equal(t, "a", string(c.Key()))
+}
+
+func TestCursorEmpty(t *testing.T) {
+	tree := Tree[int]{}
+	c := tree.Cursor()
+	assert.False(t, c.First())
+	assert.False(t, c.Last())
+	assert.False(t, c.Seek(Key("a")))
+	assert.False(t, c.Next())
+	assert.Nil(t, c.Key())
+
+	tree.Insert(Key("a"), 1)
+	require.True(t, c.Seek(nil))
+	assert.Equal(t, "a", string(c.Key()))
+	assert.False(t, c.Next())
+	require.True(t, c.Last())
+	assert.False(t, c.Prev())
+}
+
+func TestCursorProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	alphabet := []byte{0, 1, 2, 'a', 'b', 255}
+	tree := Tree[int]{}
+	expected := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		key := randomBinaryKey(rng, alphabet)
+		tree.Insert(key, i)
+		expected[string(key)] = i
+	}
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	c := tree.Cursor()
+	for i := 0; i < 1000; i++ {
+		seek := string(randomBinaryKey(rng, alphabet))
+		pos := sort.SearchStrings(keys, seek)
+		require.Equal(t, pos < len(keys), c.Seek(Key(seek)))
+		// random walk in both directions
+		for step := 0; step < 20 && pos >= 0 && pos < len(keys); step++ {
+			require.Equal(t, keys[pos], string(c.Key()), "seek %q step %d", seek, step)
+			require.Equal(t, expected[keys[pos]], c.Value())
+			if rng.Intn(2) == 0 {
+				pos++
+				require.Equal(t, pos < len(keys), c.Next())
+			} else {
+				pos--
+				require.Equal(t, pos >= 0, c.Prev())
+			}
+		}
+	}
+}
+
+func TestCursorSnapshot(t *testing.T) {
+	tree := Tree[int]{}
+	for i := 0; i < 100; i++ {
+		tree.Insert(Key(fmt.Sprintf("key::%07b", i)), i)
+	}
+	snapshot := tree.Snapshot()
+	for i := 0; i < 100; i += 2 {
+		tree.Remove(Key(fmt.Sprintf("key::%07b", i)))
+	}
+
+	c := snapshot.Cursor()
+	count := 0
+	for ok := c.First(); ok; ok = c.Next() {
+		assert.Equal(t, fmt.Sprintf("key::%07b", count), string(c.Key()))
+		count++
+	}
+	assert.Equal(t, 100, count)
+	require.True(t, c.Seek(Key(fmt.Sprintf("key::%07b", 50))))
+	require.True(t, c.Prev())
+	assert.Equal(t, 49, c.Value())
+}
+
+func TestCursorConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	tree := Tree[int]{}
+	// stable keys are never modified, the others are inserted and removed concurrently
+	const stable = 1000
+	for i := 0; i < stable; i++ {
+		tree.Insert(Key(fmt.Sprintf("key::%011b", i*2)), i)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key := Key(fmt.Sprintf("key::%011b", rng.Intn(stable)*2+1))
+				if rng.Intn(2) == 0 {
+					tree.Insert(key, -1)
+				} else {
+					tree.Remove(key)
+				}
+			}
+		}(int64(w))
+	}
+
+	for round := 0; round < 20; round++ {
+		c := tree.Cursor()
+		var seen []int
+		prev := ""
+		forward := round%2 == 0
+		ok, step := c.First(), c.Next
+		if !forward {
+			ok, step = c.Last(), c.Prev
+		}
+		for ; ok; ok = step() {
+			key := string(c.Key())
+			if prev != "" {
+				require.Equal(t, forward, key > prev, "%q after %q", key, prev)
+			}
+			prev = key
+			if v := c.Value(); v >= 0 {
+				seen = append(seen, v)
+			}
+		}
+		require.Len(t, seen, stable)
+	}
+	close(done)
+	wg.Wait()
+}
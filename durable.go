@@ -0,0 +1,267 @@
+package art
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DurableOptions configures a DurableTree.
+type DurableOptions[T any] struct {
+	// Encode and Decode convert the values to the bytes written to the log and the checkpoints.
+	Encode func(T) ([]byte, error)
+	Decode func([]byte) (T, error)
+	// SegmentSize is the size after which the log moves to a new segment, 64MiB by default.
+	SegmentSize int64
+	// NoSync skips the fsync of the log after every write. A write survives a crash
+	// of the process once it returns, but not a crash of the machine.
+	NoSync bool
+}
+
+const defaultSegmentSize = 64 << 20
+
+// ErrClosed is returned by the operations of a DurableTree after it's closed.
+var ErrClosed = errors.New("art: tree is closed")
+
+const (
+	recordInsert byte = iota + 1
+	recordRemove
+)
+
+// DurableTree is a Tree which writes every modification to a log before applying it.
+// The content of the tree is recovered from the latest checkpoint and the log by OpenDurable.
+//
+// The writes are serialized by the log, the reads don't wait for them.
+type DurableTree[T any] struct {
+	tree *Tree[T]
+	opts DurableOptions[T]
+
+	// mu serializes the writes, so that the log has the order they are applied in
+	mu  sync.Mutex
+	log *wal
+	// err is the error which left the log in an unknown state, the writes fail with it afterwards
+	err error
+
+	checkpoint sync.Mutex
+}
+
+// OpenDurable opens the tree stored in dir, it's created if it doesn't exist. The tree is loaded
+// from the latest checkpoint, and the segments of the log written after it are replayed.
+// A frame torn by a crash at the end of the log is discarded.
+func OpenDurable[T any](dir string, opts DurableOptions[T]) (*DurableTree[T], error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	segments, checkpoints, err := listLog(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DurableTree[T]{tree: &Tree[T]{}, opts: opts}
+	var seq uint64
+	if len(checkpoints) > 0 {
+		seq = checkpoints[len(checkpoints)-1]
+		f, err := os.Open(checkpointName(dir, seq))
+		if err != nil {
+			return nil, err
+		}
+		d.tree, err = Decode(f, opts.Decode)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint %d: %w", seq, err)
+		}
+	}
+	for i, segment := range segments {
+		if segment <= seq {
+			// left by a checkpoint interrupted before it removed them
+			if err := os.Remove(segmentName(dir, segment)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := replaySegment(segmentName(dir, segment), i == len(segments)-1, d.replay); err != nil {
+			return nil, err
+		}
+		seq = segment
+	}
+
+	d.log = &wal{dir: dir, segmentSize: opts.SegmentSize, noSync: opts.NoSync}
+	if err := d.log.create(seq + 1); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DurableTree[T]) replay(record []byte) error {
+	op, key, value, err := decodeRecord(record)
+	if err != nil {
+		return err
+	}
+	// the record is read from the buffer of the whole segment
+	key = append(Key(nil), key...)
+	switch op {
+	case recordInsert:
+		v, err := d.opts.Decode(value)
+		if err != nil {
+			return err
+		}
+		d.tree.Insert(key, v)
+	case recordRemove:
+		d.tree.Remove(key)
+	default:
+		return fmt.Errorf("%w: unknown record %d", ErrCorrupt, op)
+	}
+	return nil
+}
+
+// record is op | key length uvarint | key | value
+func encodeRecord(op byte, key Key, value []byte) []byte {
+	record := make([]byte, 0, 1+binary.MaxVarintLen64+len(key)+len(value))
+	record = append(record, op)
+	record = binary.AppendUvarint(record, uint64(len(key)))
+	record = append(record, key...)
+	return append(record, value...)
+}
+
+func decodeRecord(record []byte) (op byte, key Key, value []byte, err error) {
+	if len(record) == 0 {
+		return 0, nil, nil, fmt.Errorf("%w: empty record", ErrCorrupt)
+	}
+	length, n := binary.Uvarint(record[1:])
+	if n <= 0 || uint64(len(record)-1-n) < length {
+		return 0, nil, nil, fmt.Errorf("%w: invalid record key", ErrCorrupt)
+	}
+	key = record[1+n : 1+n+int(length)]
+	return record[0], key, record[1+n+int(length):], nil
+}
+
+// write appends the record to the log and applies it to the tree.
+func (d *DurableTree[T]) write(record []byte, apply func()) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	if err := d.log.append(record); err != nil {
+		d.err = err
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Insert writes the value of key to the log, then stores it in the tree.
+func (d *DurableTree[T]) Insert(key Key, value T) (updated bool, err error) {
+	encoded, err := d.opts.Encode(value)
+	if err != nil {
+		return false, err
+	}
+	err = d.write(encodeRecord(recordInsert, key, encoded), func() {
+		updated = d.tree.Insert(key, value)
+	})
+	return updated, err
+}
+
+// Remove writes the removal of key to the log, then removes it from the tree.
+func (d *DurableTree[T]) Remove(key Key) (deleted bool, value T, err error) {
+	err = d.write(encodeRecord(recordRemove, key, nil), func() {
+		deleted, value = d.tree.Remove(key)
+	})
+	return deleted, value, err
+}
+
+// Search returns the value of key.
+func (d *DurableTree[T]) Search(key Key) (value T, found bool) {
+	return d.tree.Search(key)
+}
+
+// Len returns the number of keys in the tree.
+func (d *DurableTree[T]) Len() int {
+	return d.tree.Len()
+}
+
+// Snapshot returns a point-in-time view of the tree, see Tree.Snapshot.
+func (d *DurableTree[T]) Snapshot() *Snapshot[T] {
+	return d.tree.Snapshot()
+}
+
+// Checkpoint writes the content of the tree to a checkpoint and removes the segments of the log
+// it covers. The writes continue while the checkpoint is written, to the next segment of the log.
+func (d *DurableTree[T]) Checkpoint() error {
+	d.checkpoint.Lock()
+	defer d.checkpoint.Unlock()
+
+	d.mu.Lock()
+	if d.err != nil {
+		d.mu.Unlock()
+		return d.err
+	}
+	// the snapshot has exactly the writes of the segments up to seq
+	seq := d.log.seq
+	if err := d.log.rotate(); err != nil {
+		d.err = err
+		d.mu.Unlock()
+		return err
+	}
+	snapshot := d.tree.Snapshot()
+	d.mu.Unlock()
+
+	name := checkpointName(d.log.dir, seq)
+	f, err := os.Create(name + tempExt)
+	if err != nil {
+		return err
+	}
+	if _, err := snapshot.Encode(f, d.opts.Encode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := d.log.sync(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name+tempExt, name); err != nil {
+		return err
+	}
+	if err := d.log.sync(nil); err != nil {
+		return err
+	}
+
+	segments, checkpoints, err := listLog(d.log.dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment <= seq {
+			if err := os.Remove(segmentName(d.log.dir, segment)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint < seq {
+			if err := os.Remove(checkpointName(d.log.dir, checkpoint)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the log, the writes fail with ErrClosed afterwards.
+func (d *DurableTree[T]) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == ErrClosed {
+		return nil
+	}
+	d.err = ErrClosed
+	return d.log.close()
+}
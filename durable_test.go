@@ -0,0 +1,204 @@
+package art
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDurable(t *testing.T, dir string, segmentSize int64) *DurableTree[int] {
+	tree, err := OpenDurable(dir, DurableOptions[int]{
+		Encode:      encodeInt,
+		Decode:      decodeInt,
+		SegmentSize: segmentSize,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { tree.Close() })
+	return tree
+}
+
+func requireContent(t *testing.T, expected map[string]int, tree *DurableTree[int]) {
+	require.Equal(t, len(expected), tree.Len())
+	for key, value := range expected {
+		got, found := tree.Search(Key(key))
+		require.Truef(t, found, "search %q", key)
+		require.Equal(t, value, got)
+	}
+}
+
+func logFiles(t *testing.T, dir, ext string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	require.NoError(t, err)
+	return files
+}
+
+func TestDurableTree(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, 0)
+	expected := map[string]int{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key::%d", i%30)
+		if i%4 == 3 {
+			deleted, _, err := tree.Remove(Key(key))
+			require.NoError(t, err)
+			_, exists := expected[key]
+			assert.Equal(t, exists, deleted)
+			delete(expected, key)
+			continue
+		}
+		_, err := tree.Insert(Key(key), i)
+		require.NoError(t, err)
+		expected[key] = i
+	}
+	requireContent(t, expected, tree)
+	require.NoError(t, tree.Close())
+	_, err := tree.Insert(Key("closed"), 0)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	requireContent(t, expected, openDurable(t, dir, 0))
+	// reopened without being closed, like after a crash
+	crashed := openDurable(t, dir, 0)
+	_, err = crashed.Insert(Key("after crash"), 1)
+	require.NoError(t, err)
+	expected["after crash"] = 1
+	requireContent(t, expected, openDurable(t, dir, 0))
+}
+
+func TestDurableTree_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, 0)
+	for i := 0; i < 10; i++ {
+		_, err := tree.Insert(Key(fmt.Sprintf("key::%d", i)), i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tree.Close())
+
+	segments := logFiles(t, dir, segmentExt)
+	require.Len(t, segments, 1)
+	info, err := os.Stat(segments[0])
+	require.NoError(t, err)
+	// the last frame is cut in the middle
+	require.NoError(t, os.Truncate(segments[0], info.Size()-3))
+
+	tree = openDurable(t, dir, 0)
+	expected := map[string]int{}
+	for i := 0; i < 9; i++ {
+		expected[fmt.Sprintf("key::%d", i)] = i
+	}
+	requireContent(t, expected, tree)
+	info, err = os.Stat(segments[0])
+	require.NoError(t, err)
+	// a frame is the header, the op, the key length, the key and the value
+	assert.Equal(t, int64(9*(frameHeader+3+len("key::0"))), info.Size())
+
+	// the log is written after the truncated tail
+	_, err = tree.Insert(Key("key::9"), 9)
+	require.NoError(t, err)
+	expected["key::9"] = 9
+	require.NoError(t, tree.Close())
+	requireContent(t, expected, openDurable(t, dir, 0))
+}
+
+func TestDurableTree_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, 64)
+	for i := 0; i < 100; i++ {
+		_, err := tree.Insert(Key(fmt.Sprintf("key::%d", i)), i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tree.Close())
+	segments := logFiles(t, dir, segmentExt)
+	require.Greater(t, len(segments), 2)
+
+	// a frame in the middle of the log isn't a torn tail
+	data, err := os.ReadFile(segments[0])
+	require.NoError(t, err)
+	data[frameHeader] ^= 0xff
+	require.NoError(t, os.WriteFile(segments[0], data, 0o644))
+	_, err = OpenDurable(dir, DurableOptions[int]{Encode: encodeInt, Decode: decodeInt})
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestDurableTree_Checkpoint(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, 64)
+	expected := map[string]int{}
+	for i := 0; i < 100; i++ {
+		_, err := tree.Insert(Key(fmt.Sprintf("key::%d", i)), i)
+		require.NoError(t, err)
+		expected[fmt.Sprintf("key::%d", i)] = i
+	}
+	require.NoError(t, tree.Checkpoint())
+	assert.Len(t, logFiles(t, dir, checkpointExt), 1)
+	assert.Len(t, logFiles(t, dir, segmentExt), 1)
+
+	for i := 0; i < 50; i++ {
+		_, _, err := tree.Remove(Key(fmt.Sprintf("key::%d", i)))
+		require.NoError(t, err)
+		delete(expected, fmt.Sprintf("key::%d", i))
+	}
+	require.NoError(t, tree.Checkpoint())
+	assert.Len(t, logFiles(t, dir, checkpointExt), 1)
+	_, err := tree.Insert(Key("after checkpoint"), 1)
+	require.NoError(t, err)
+	expected["after checkpoint"] = 1
+	require.NoError(t, tree.Close())
+
+	// a checkpoint interrupted before it was renamed is ignored
+	require.NoError(t, os.WriteFile(checkpointName(dir, 1<<40)+tempExt, []byte("partial"), 0o644))
+	requireContent(t, expected, openDurable(t, dir, 64))
+	assert.Empty(t, logFiles(t, dir, tempExt))
+}
+
+func TestDurableTree_ConcurrentCheckpoint(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 4
+		keys    = 500
+	)
+	dir := t.TempDir()
+	tree, err := OpenDurable(dir, DurableOptions[int]{Encode: encodeInt, Decode: decodeInt, SegmentSize: 1024, NoSync: true})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				key := Key(fmt.Sprintf("key::%d::%d", w, i))
+				_, err := tree.Insert(key, i)
+				assert.NoError(t, err)
+				if i%3 == 0 {
+					_, _, err = tree.Remove(key)
+					assert.NoError(t, err)
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			require.NoError(t, tree.Checkpoint())
+		}
+	}
+
+	expected := map[string]int{}
+	for iter := tree.Snapshot().Iterator(nil, nil); iter.Next(); {
+		expected[string(iter.Key())] = iter.Value()
+	}
+	require.Len(t, expected, workers*(keys-keys/3-1))
+	require.NoError(t, tree.Close())
+	requireContent(t, expected, openDurable(t, dir, 1024))
+}
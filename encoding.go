@@ -0,0 +1,236 @@
+package art
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// The encoding of a tree is
+//
+//	magic "SART" | version byte | count uvarint | count records | crc32 uint32
+//
+// a record is a key and its encoded value, in the key order:
+//
+//	key length uvarint | key | value length uvarint | value
+//
+// The checksum is the CRC-32C of all the bytes before it, in little endian.
+const (
+	encodingMagic   = "SART"
+	encodingVersion = 1
+)
+
+var (
+	// ErrCorrupt is returned by Decode when the data is truncated, or doesn't match its checksum.
+	ErrCorrupt = errors.New("art: corrupt data")
+	// ErrUnsupportedVersion is returned by Decode when the data is of an unknown version of the encoding.
+	ErrUnsupportedVersion = errors.New("art: unsupported encoding version")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Encode writes the keys and the values of the tree to w, enc encodes a value.
+// It returns the number of bytes written.
+//
+// The tree is encoded from a snapshot, writers can continue while it's encoded
+// and none of their changes made after Encode is called are written.
+func (t *Tree[T]) Encode(w io.Writer, enc func(T) ([]byte, error)) (int64, error) {
+	return t.Snapshot().Encode(w, enc)
+}
+
+// Encode writes the keys and the values of the snapshot to w, see Tree.Encode.
+func (s *Snapshot[T]) Encode(w io.Writer, enc func(T) ([]byte, error)) (int64, error) {
+	e := &encoder{w: bufio.NewWriter(w), crc: crc32.New(castagnoli)}
+	e.write([]byte(encodingMagic))
+	e.write([]byte{encodingVersion})
+	e.uvarint(uint64(s.Len()))
+	for iter := s.Iterator(nil, nil); iter.Next() && e.err == nil; {
+		value, err := enc(iter.Value())
+		if err != nil {
+			return e.n, err
+		}
+		e.uvarint(uint64(len(iter.Key())))
+		e.write(iter.Key())
+		e.uvarint(uint64(len(value)))
+		e.write(value)
+	}
+	e.write(binary.LittleEndian.AppendUint32(nil, e.crc.Sum32()))
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.n, e.err
+}
+
+// Decode reads a tree written by Encode from r, dec decodes a value. The slice
+// passed to dec isn't reused, the decoded value may retain it.
+//
+// The tree is bulk loaded like BuildFromSorted. All the data is read before the
+// tree is returned, the errors of dec are returned only if the data isn't corrupt.
+func Decode[T any](r io.Reader, dec func([]byte) (T, error)) (*Tree[T], error) {
+	d := &decoder{crc: crc32.New(castagnoli)}
+	if br, ok := r.(byteReader); ok {
+		d.r = br
+	} else {
+		d.r = bufio.NewReader(r)
+	}
+	header, err := d.read(len(encodingMagic) + 1)
+	if err != nil {
+		return nil, err
+	}
+	if string(header[:len(encodingMagic)]) != encodingMagic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrCorrupt, header[:len(encodingMagic)])
+	}
+	if version := header[len(encodingMagic)]; version != encodingVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	count, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	// the records left after an error are read to verify the checksum
+	var readErr, valueErr error
+	tree, buildErr := BuildFromSorted(func() (key Key, value T, ok bool) {
+		if count == 0 || valueErr != nil {
+			return key, value, false
+		}
+		count--
+		var encoded []byte
+		if key, encoded, readErr = d.record(); readErr != nil {
+			return key, value, false
+		}
+		if value, valueErr = dec(encoded); valueErr != nil {
+			return key, value, false
+		}
+		return key, value, true
+	})
+	for ; readErr == nil && count > 0; count-- {
+		_, _, readErr = d.record()
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	sum := d.crc.Sum32()
+	trailer, err := d.read(4)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(trailer) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	if buildErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, buildErr)
+	}
+	return tree, nil
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	n   int64
+	err error
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	var n int
+	n, e.err = e.w.Write(b)
+	e.n += int64(n)
+	e.crc.Write(b[:n])
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.write(binary.AppendUvarint(nil, v))
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type decoder struct {
+	r   byteReader
+	crc hash.Hash32
+	// err is the last error of ReadByte
+	err error
+}
+
+// maxChunk is the largest slice allocated up front for a length read from the data,
+// longer ones grow as they are read, a corrupt length can't allocate more than the data.
+const maxChunk = 1 << 20
+
+func (d *decoder) read(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, truncated(err)
+	}
+	d.crc.Write(b)
+	return b, nil
+}
+
+func (d *decoder) ReadByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err == nil {
+		d.crc.Write([]byte{c})
+	}
+	d.err = err
+	return c, err
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d)
+	if err != nil && d.err == nil {
+		// not an error of the reader, the varint overflows
+		return 0, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if err != nil {
+		return 0, truncated(err)
+	}
+	return v, nil
+}
+
+func (d *decoder) record() (key Key, value []byte, err error) {
+	if key, err = d.bytes(); err != nil {
+		return nil, nil, err
+	}
+	value, err = d.bytes()
+	return key, value, err
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n <= maxChunk {
+		return d.read(int(n))
+	}
+	if n > 1<<62 {
+		return nil, fmt.Errorf("%w: length %d", ErrCorrupt, n)
+	}
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, d.r, int64(n)); err != nil {
+		return nil, truncated(err)
+	}
+	d.crc.Write(b.Bytes())
+	return b.Bytes(), nil
+}
+
+// truncated reports the end of the data before the end of the encoding as corruption.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrCorrupt, io.ErrUnexpectedEOF)
+	}
+	return err
+}
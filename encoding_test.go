@@ -0,0 +1,130 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeInt(v int) ([]byte, error) {
+	return []byte(strconv.Itoa(v)), nil
+}
+
+func decodeInt(b []byte) (int, error) {
+	return strconv.Atoi(string(b))
+}
+
+func TestTree_Encode(t *testing.T) {
+	for _, keys := range [][]string{
+		nil,
+		{"a"},
+		{"", "a", "ab", "abc", "abd", "b", "this_is_a_long_prefix::1", "this_is_a_long_prefix::2"},
+	} {
+		tree := Tree[int]{}
+		for i, key := range keys {
+			tree.Insert(Key(key), i)
+		}
+		var buf bytes.Buffer
+		n, err := tree.Encode(&buf, encodeInt)
+		require.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		decoded, err := Decode(&buf, decodeInt)
+		require.NoError(t, err)
+		assert.Equal(t, len(keys), decoded.Len())
+		for i, key := range keys {
+			value, found := decoded.Search(Key(key))
+			require.Truef(t, found, "search %q", key)
+			assert.Equal(t, i, value)
+		}
+	}
+
+	// a value longer than the chunk allocated up front
+	tree := Tree[[]byte]{}
+	large := bytes.Repeat([]byte("v"), maxChunk+1)
+	tree.Insert(Key("large"), large)
+	var buf bytes.Buffer
+	_, err := tree.Encode(&buf, func(v []byte) ([]byte, error) { return v, nil })
+	require.NoError(t, err)
+	decoded, err := Decode(&buf, func(b []byte) ([]byte, error) { return b, nil })
+	require.NoError(t, err)
+	value, _ := decoded.Search(Key("large"))
+	assert.Equal(t, large, value)
+}
+
+func TestTree_EncodeErrors(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a"), 1)
+	encodeErr := errors.New("encode")
+	_, err := tree.Encode(&bytes.Buffer{}, func(int) ([]byte, error) { return nil, encodeErr })
+	assert.ErrorIs(t, err, encodeErr)
+
+	var buf bytes.Buffer
+	_, err = tree.Encode(&buf, encodeInt)
+	require.NoError(t, err)
+	decodeErr := errors.New("decode")
+	_, err = Decode(&buf, func([]byte) (int, error) { return 0, decodeErr })
+	assert.ErrorIs(t, err, decodeErr)
+}
+
+func TestDecode_Corrupt(t *testing.T) {
+	tree := Tree[int]{}
+	for i := 0; i < 100; i++ {
+		tree.Insert(Key(fmt.Sprintf("key::%d", i)), i)
+	}
+	var buf bytes.Buffer
+	_, err := tree.Encode(&buf, encodeInt)
+	require.NoError(t, err)
+	encoded := buf.Bytes()
+
+	for i := range encoded {
+		_, err := Decode(bytes.NewReader(encoded[:i]), decodeInt)
+		require.ErrorIsf(t, err, ErrCorrupt, "truncated at %d", i)
+	}
+	for i := range encoded {
+		corrupt := append([]byte(nil), encoded...)
+		corrupt[i] ^= 0x41
+		_, err := Decode(bytes.NewReader(corrupt), decodeInt)
+		require.Errorf(t, err, "flipped byte %d", i)
+		if i != len(encodingMagic) {
+			require.ErrorIsf(t, err, ErrCorrupt, "flipped byte %d", i)
+		}
+	}
+	_, err = Decode(bytes.NewReader(append(encoded[:len(encodingMagic):len(encodingMagic)], encodingVersion+1)), decodeInt)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestTree_EncodeConcurrentWriters(t *testing.T) {
+	t.Parallel()
+	const keys = 10000
+	tree := Tree[int]{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < keys; i++ {
+			tree.Insert(Key(fmt.Sprintf("key::%05d", i)), i)
+		}
+	}()
+	// the keys are inserted in order, every encoding is a prefix of them
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		_, err := tree.Encode(&buf, encodeInt)
+		require.NoError(t, err)
+		decoded, err := Decode(&buf, decodeInt)
+		require.NoError(t, err)
+		expected := 0
+		for iter := decoded.Iterator(nil, nil); iter.Next(); expected++ {
+			require.Equal(t, fmt.Sprintf("key::%05d", expected), string(iter.Key()))
+			require.Equal(t, expected, iter.Value())
+		}
+		require.Equal(t, expected, decoded.Len())
+	}
+	wg.Wait()
+}
@@ -1,6 +1,7 @@
 package art
 
 import (
+	"bytes"
 	"fmt"
 )
 
@@ -20,6 +21,9 @@ func (n *inner[T]) Kind() Kind {
 }
 
 func (n *inner[T]) leftmost() node[T] {
+	if n.leaf != nil {
+		return n.leaf
+	}
 	return n.node.leftmost()
 }
 
@@ -55,87 +59,153 @@ func (n *inner[T]) prefixMismatch(key Key, depth int) (idx int) {
 	return
 }
 
+// comparePath compares key from depth with the prefix of the node, the bytes beyond
+// maxPrefixLen are read from the leftmost leaf. A key running out within the prefix
+// is before all the keys of the node.
+func (n *inner[T]) comparePath(key Key, depth int) int {
+	prefix := n.prefix[:min(n.prefixLen, maxPrefixLen)]
+	if l, ok := n.leftmost().(*leaf[T]); ok && n.prefixLen > maxPrefixLen && len(l.key) >= depth+n.prefixLen {
+		prefix = l.key[depth : depth+n.prefixLen]
+	}
+	rest := key[min(depth, len(key)):]
+	if len(rest) > len(prefix) {
+		rest = rest[:len(prefix)]
+	}
+	return bytes.Compare(rest, prefix)
+}
+
 func (n *inner[T]) setPrefix(key []byte, len int) {
 	n.prefixLen = len
 	copy(n.prefix[:], key[:min(len, maxPrefixLen)])
 }
 
+// addLeaf adds l to the node, the leaf goes to the child slot of the byte at nextDepth,
+// or becomes the leaf of the node if the key terminates at nextDepth.
+func (n *inner[T]) addLeaf(l *leaf[T], nextDepth int) {
+	if len(l.key) == nextDepth {
+		n.leaf = l
+		return
+	}
+	if n.node.full() {
+		n.node = n.node.grow()
+	}
+	n.node.addChild(l.key[nextDepth], l)
+}
+
+// clone returns a copy of the node owned by epoch, the copy shares the children and the leaf with the node.
+func (n *inner[T]) clone(epoch *generation) *inner[T] {
+	return &inner[T]{
+		prefix:    n.prefix,
+		prefixLen: n.prefixLen,
+		leaf:      n.leaf,
+		node:      n.node.clone(),
+		epoch:     epoch,
+	}
+}
+
+// frozen returns child as an inner node if it is shared with a snapshot, it must be
+// thawed before it's modified.
+func (n *inner[T]) frozen(child node[T]) (*inner[T], bool) {
+	c, ok := child.(*inner[T])
+	return c, ok && c.epoch != n.epoch
+}
+
+// thaw replaces the frozen child at idx with a copy owned by the epoch of the node.
+// The node must be write locked, the frozen child is marked obsolete before the node
+// is unlocked, so that operations still holding it restart and find the copy.
+// Nothing is done if the node itself has been frozen since, the caller restarts.
+func (n *inner[T]) thaw(idx int, child *inner[T]) {
+	if n.epoch.isFrozen() {
+		return
+	}
+	child.lock.Lock()
+	n.node.replace(idx, child.clone(n.epoch))
+	child.lock.UnlockObsolete()
+}
+
+// split moves the content of the node into a new child, when l diverges from the prefix
+// of the node at mismatch. The node keeps the shared part of the prefix and gets l.
+// The node and its parent must be write locked.
+func (n *inner[T]) split(l *leaf[T], depth, mismatch int) {
+	// lazy expend
+	//
+	//     		this_is_a_long_prefix (current node)   <----- try to insert a leaf: this_is_leaf
+	//  		*
+	//  	*
+	//  *
+	// (1) index char
+	// this_is_a_long_prefix1 (leaf)
+
+	//     		this_is_ (node) 				  		   <----- new shared node
+	//  		*           *
+	//  	*		   	   		*
+	// 	*							*
+	// (l) index char				(a) index char
+	// this_is_leaf (leaf)  		a_long_prefix (node)   <---- expanded node (current node) {prefix-sharedPrefix}
+	// 								*
+	// 							*
+	// 						*
+	// 					*
+	// 				(1) index char
+	//  			this_is_a_long_prefix1 (leaf)
+
+	// current node will as child of n.node
+	current := &inner[T]{
+		node:      n.node,
+		leaf:      n.leaf,
+		prefixLen: n.prefixLen,
+		epoch:     n.epoch,
+	}
+	// make a copy here
+	copy(current.prefix[:], n.prefix[:])
+
+	// n.node as a shared node
+	n.node = &node4[T]{}
+	n.leaf = nil
+	// set prefix
+	n.setPrefix(current.prefix[:min(maxPrefixLen, mismatch)], mismatch)
+
+	if current.prefixLen <= maxPrefixLen {
+		current.prefixLen -= mismatch + 1
+		n.node.addChild(current.prefix[mismatch], current)
+		// set current node's  prefix to {prefix - sharedPrefix}
+		if current.prefixLen > 0 {
+			copy(
+				current.prefix[:],
+				current.prefix[mismatch+1:min(mismatch+1+min(maxPrefixLen, current.prefixLen), maxPrefixLen)],
+			)
+		}
+	} else { // prefixMismatchedId > maxPrefixLen
+		current.prefixLen -= mismatch + 1
+		leftmost := current.leftmost().(*leaf[T])
+		n.node.addChild(leftmost.key[depth+mismatch], current)
+		// set current node's prefix to {leftmost prefix - sharedPrefix}
+		if current.prefixLen > 0 {
+			copy(
+				current.prefix[:],
+				leftmost.key[depth+mismatch+1:depth+mismatch+1+min(maxPrefixLen, current.prefixLen)],
+			)
+		}
+	}
+	// add, the key might terminate at the shared node
+	n.addLeaf(l, depth+mismatch)
+}
+
 func (n *inner[T]) insert(l *leaf[T], depth int, parent *olock, parentVersion uint64) (node[T], bool, bool) {
 	for {
 		version, obsolete := n.lock.RLock()
-		if obsolete {
+		if obsolete || n.epoch.isFrozen() {
 			return n, true, false
 		}
 		//prefixMismatchedIdx := comparePrefix(n.prefix[:n.prefixLen], l.key, 0, depth)
 		prefixMismatchedIdx := n.prefixMismatch(l.key, depth)
 
 		if prefixMismatchedIdx < n.prefixLen {
-			if parent.Upgrade(parentVersion, nil) {
-				return nil, true, false
-			}
-			if n.lock.Upgrade(version, parent) {
+			if _, restart := n.upgrade(version, parent, parentVersion, true); restart {
 				return nil, true, false
 			}
 
-			// lazy expend
-			//
-			//     		this_is_a_long_prefix (current node)   <----- try to insert a leaf: this_is_leaf
-			//  		*
-			//  	*
-			//  *
-			// (1) index char
-			// this_is_a_long_prefix1 (leaf)
-
-			//     		this_is_ (node) 				  		   <----- new shared node
-			//  		*           *
-			//  	*		   	   		*
-			// 	*							*
-			// (l) index char				(a) index char
-			// this_is_leaf (leaf)  		a_long_prefix (node)   <---- expanded node (current node) {prefix-sharedPrefix}
-			// 								*
-			// 							*
-			// 						*
-			// 					*
-			// 				(1) index char
-			//  			this_is_a_long_prefix1 (leaf)
-
-			// current node will as child of n.node
-			current := &inner[T]{
-				node:      n.node,
-				prefixLen: n.prefixLen,
-			}
-			// make a copy here
-			copy(current.prefix[:], n.prefix[:])
-
-			// n.node as a shared node
-			n.node = &node4[T]{}
-			// set prefix
-			n.setPrefix(current.prefix[:min(maxPrefixLen, prefixMismatchedIdx)], prefixMismatchedIdx)
-
-			if current.prefixLen <= maxPrefixLen {
-				current.prefixLen -= prefixMismatchedIdx + 1
-				n.node.addChild(current.prefix[prefixMismatchedIdx], current)
-				// set current node's  prefix to {prefix - sharedPrefix}
-				if current.prefixLen > 0 {
-					copy(
-						current.prefix[:],
-						current.prefix[prefixMismatchedIdx+1:min(prefixMismatchedIdx+1+min(maxPrefixLen, current.prefixLen), maxPrefixLen)],
-					)
-				}
-			} else { // prefixMismatchedId > maxPrefixLen
-				current.prefixLen -= prefixMismatchedIdx + 1
-				leftmost := current.leftmost().(*leaf[T])
-				n.node.addChild(leftmost.key.At(depth+prefixMismatchedIdx), current)
-				// set current node's prefix to {leftmost prefix - sharedPrefix}
-				if current.prefixLen > 0 {
-					copy(
-						current.prefix[:],
-						leftmost.key[depth+prefixMismatchedIdx+1:depth+prefixMismatchedIdx+1+min(maxPrefixLen, current.prefixLen)],
-					)
-				}
-			}
-			// add
-			n.node.addChild(l.key.At(depth+prefixMismatchedIdx), l)
+			n.split(l, depth, prefixMismatchedIdx)
 
 			n.lock.Unlock()
 			parent.Unlock()
@@ -143,19 +213,27 @@ func (n *inner[T]) insert(l *leaf[T], depth int, parent *olock, parentVersion ui
 		}
 
 		nextDepth := depth + n.prefixLen
-		idx, next := n.node.child(l.key.At(nextDepth))
-
-		if next == nil {
-			if n.lock.Upgrade(version, nil) {
+		if len(l.key) == nextDepth {
+			// the key terminates at this node
+			if retry, restart := n.upgrade(version, parent, parentVersion, false); restart {
+				return n, true, false
+			} else if retry {
 				continue
 			}
-			if parent.RUnlock(parentVersion, &n.lock) {
+			updated := n.leaf != nil
+			n.leaf = l
+			n.lock.Unlock()
+			return n, false, updated
+		}
+		idx, next := n.node.child(l.key[nextDepth])
+
+		if next == nil {
+			if retry, restart := n.upgrade(version, parent, parentVersion, false); restart {
 				return n, true, false
+			} else if retry {
+				continue
 			}
-			if n.node.full() {
-				n.node = n.node.grow()
-			}
-			n.node.addChild(l.key.At(nextDepth), l)
+			n.addLeaf(l, nextDepth)
 			n.lock.Unlock()
 			return n, false, false
 		}
@@ -166,14 +244,27 @@ func (n *inner[T]) insert(l *leaf[T], depth int, parent *olock, parentVersion ui
 			continue
 		}
 		if _, ok := next.(*leaf[T]); ok {
-			if n.lock.Upgrade(version, nil) {
+			if retry, restart := n.upgrade(version, parent, parentVersion, false); restart {
+				return n, true, false
+			} else if retry {
 				continue
 			}
 			replacement, _, updated := next.insert(l, nextDepth+1, &n.lock, version)
+			if nn, ok := replacement.(*inner[T]); ok {
+				nn.epoch = n.epoch
+			}
 			n.node.replace(idx, replacement)
 			n.lock.Unlock()
 			return n, false, updated
 		}
+		if c, ok := n.frozen(next); ok {
+			if n.lock.Upgrade(version, nil) {
+				continue
+			}
+			n.thaw(idx, c)
+			n.lock.Unlock()
+			continue
+		}
 
 		_, restart, updated := next.insert(l, nextDepth+1, &n.lock, version)
 		if restart {
@@ -186,12 +277,13 @@ func (n *inner[T]) insert(l *leaf[T], depth int, parent *olock, parentVersion ui
 func (n *inner[T]) del(key Key, depth int, parent *olock, parentVersion uint64, parentUpdate func(node[T])) (deleted, restart bool, deletedNode node[T]) {
 	for {
 		version, obsolete := n.lock.RLock()
-		if obsolete {
+		if obsolete || n.epoch.isFrozen() {
 			return false, true, deletedNode
 		}
 
 		cmp := n.checkPrefix(key, depth)
-		if cmp != min(n.prefixLen, maxPrefixLen) {
+		nextDepth := depth + n.prefixLen
+		if cmp != min(n.prefixLen, maxPrefixLen) || len(key) < nextDepth {
 			// key is not found, check for concurrent writes and exit
 			if n.lock.RUnlock(version, nil) {
 				continue
@@ -199,8 +291,37 @@ func (n *inner[T]) del(key Key, depth int, parent *olock, parentVersion uint64,
 			return false, parent.RUnlock(parentVersion, nil), deletedNode
 		}
 
-		nextDepth := depth + n.prefixLen
-		idx, next := n.node.child(key.At(nextDepth))
+		if len(key) == nextDepth {
+			// the key terminates at this node
+			if n.leaf == nil || !n.leaf.cmp(key) {
+				if n.lock.RUnlock(version, nil) {
+					continue
+				}
+				return false, parent.RUnlock(parentVersion, nil), deletedNode
+			}
+			if n.node.size() == 1 {
+				// the only child is left, current node will be collapsed.
+				if _, restart := n.upgrade(version, parent, parentVersion, true); restart {
+					return false, true, deletedNode
+				}
+				deletedNode = n.leaf
+				n.leaf = nil
+				n.collapse(parentUpdate)
+				parent.Unlock()
+				return true, false, deletedNode
+			}
+			if retry, restart := n.upgrade(version, parent, parentVersion, false); restart {
+				return false, true, deletedNode
+			} else if retry {
+				continue
+			}
+			deletedNode = n.leaf
+			n.leaf = nil
+			n.lock.Unlock()
+			return true, false, deletedNode
+		}
+
+		idx, next := n.node.child(key[nextDepth])
 		if next == nil {
 			// key is not found, check for concurrent writes and exit
 			if n.lock.RUnlock(version, nil) {
@@ -210,40 +331,14 @@ func (n *inner[T]) del(key Key, depth int, parent *olock, parentVersion uint64,
 		}
 
 		if l, isLeaf := next.(*leaf[T]); isLeaf && l.cmp(key) {
-			_, isNode4 := n.node.(*node4[T])
-			min := n.node.min()
-			if isNode4 && min {
-				// update parent pointer. current node will be collapsed.
-				if parent.Upgrade(parentVersion, nil) {
-					return false, true, deletedNode
-				}
-				if n.lock.Upgrade(version, parent) {
-					// need to update parent version
-					return false, true, deletedNode
-				}
-				deletedNode = n.node.replace(idx, nil)
-				// get the left node
-				leftB, left := n.node.next(nil)
-				left.addPrefixBefore(n, leftB)
-				parentUpdate(left)
-
-				n.lock.Unlock()
-				parent.Unlock()
-				return true, false, deletedNode
-			}
-			// local change. parent lock won't be required
-			if n.lock.Upgrade(version, nil) {
+			removed, retry, restart := n.removeChild(idx, version, parent, parentVersion, parentUpdate)
+			if retry {
 				continue
 			}
-			if parent.RUnlock(parentVersion, &n.lock) {
+			if restart {
 				return false, true, deletedNode
 			}
-			deletedNode = n.node.replace(idx, nil)
-			if min && !isNode4 {
-				n.node = n.node.shrink()
-			}
-			n.lock.Unlock()
-			return true, false, deletedNode
+			return true, false, removed
 		} else if isLeaf {
 			// key is not found. check for concurrent writes and exit
 			if n.lock.RUnlock(version, nil) {
@@ -255,6 +350,14 @@ func (n *inner[T]) del(key Key, depth int, parent *olock, parentVersion uint64,
 		if parent.RUnlock(parentVersion, nil) {
 			return false, true, deletedNode
 		}
+		if c, ok := n.frozen(next); ok {
+			if n.lock.Upgrade(version, nil) {
+				continue
+			}
+			n.thaw(idx, c)
+			n.lock.Unlock()
+			continue
+		}
 
 		if deleted, restart, deletedNode = next.del(key, nextDepth+1, &n.lock, version, func(rn node[T]) {
 			n.node.replace(idx, rn)
@@ -265,6 +368,390 @@ func (n *inner[T]) del(key Key, depth int, parent *olock, parentVersion uint64,
 	}
 }
 
+// compute calls fn with the leaf of key, nil if the key is missing, and applies the returned action.
+// fn is called once, after all the nodes which the action might change are write locked.
+// delta is the change of the number of keys in the tree.
+func (n *inner[T]) compute(key Key, depth int, parent *olock, parentVersion uint64, parentUpdate func(node[T]), fn func(*leaf[T]) (T, Action)) (delta int, restart bool) {
+	for {
+		version, obsolete := n.lock.RLock()
+		if obsolete || n.epoch.isFrozen() {
+			return 0, true
+		}
+
+		mismatch := n.prefixMismatch(key, depth)
+		nextDepth := depth + n.prefixLen
+		var lockParent bool
+		switch {
+		case mismatch < n.prefixLen:
+			// the key is missing, storing it splits the node
+			lockParent = true
+		case len(key) == nextDepth:
+			// the key terminates at this node, removing it collapses a node with a single child
+			lockParent = n.leaf != nil && n.node.size() == 1
+		default:
+			idx, next := n.node.child(key[nextDepth])
+			if child, ok := next.(*inner[T]); ok {
+				if parent.RUnlock(parentVersion, nil) {
+					return 0, true
+				}
+				if child.epoch != n.epoch {
+					if n.lock.Upgrade(version, nil) {
+						continue
+					}
+					n.thaw(idx, child)
+					n.lock.Unlock()
+					continue
+				}
+				if n.lock.Check(version) {
+					continue
+				}
+				update := func(rn node[T]) {
+					n.node.replace(idx, rn)
+				}
+				if delta, restart = child.compute(key, nextDepth+1, &n.lock, version, update, fn); restart {
+					continue
+				}
+				return delta, false
+			}
+			// the key is either the leaf child or missing
+			l, isLeaf := next.(*leaf[T])
+			lockParent = isLeaf && l.cmp(key) && n.entries() == 2
+		}
+
+		if retry, restart := n.upgrade(version, parent, parentVersion, lockParent); restart {
+			return 0, true
+		} else if retry {
+			continue
+		}
+		collapsed := false
+		delta, _ = n.apply(key, depth, mismatch, fn, func() bool {
+			n.collapse(parentUpdate)
+			parent.Unlock()
+			collapsed = true
+			return true
+		})
+		if !collapsed {
+			n.lock.Unlock()
+			if lockParent {
+				parent.Unlock()
+			}
+		}
+		return delta, false
+	}
+}
+
+// removeChild removes the child at idx from the node read at version.
+// The node is collapsed if only one entry is left in it, which requires the parent to be write locked as well.
+// retry is true if the node has been changed since version, restart is true if the parent has been changed.
+func (n *inner[T]) removeChild(idx int, version uint64, parent *olock, parentVersion uint64, parentUpdate func(node[T])) (removed node[T], retry, restart bool) {
+	collapse := n.entries() == 2
+	if retry, restart = n.upgrade(version, parent, parentVersion, collapse); retry || restart {
+		return nil, retry, restart
+	}
+	return n.removeAt(idx, collapse, parent, parentUpdate), false, false
+}
+
+// entries returns the number of children of the node, its leaf included.
+func (n *inner[T]) entries() int {
+	if n.leaf != nil {
+		return n.node.size() + 1
+	}
+	return n.node.size()
+}
+
+// upgrade write locks the node read at version. The parent is write locked as well if
+// lockParent is true, otherwise it is only checked for changes.
+// retry is true if the node has been changed since version, restart is true if the parent has been
+// changed, or if the node has been frozen by a snapshot: it must be copied from the root first.
+func (n *inner[T]) upgrade(version uint64, parent *olock, parentVersion uint64, lockParent bool) (retry, restart bool) {
+	if lockParent {
+		if parent.Upgrade(parentVersion, nil) {
+			return false, true
+		}
+		// the parent is unlocked if the node has been changed
+		if n.lock.Upgrade(version, parent) {
+			return false, true
+		}
+	} else {
+		if n.lock.Upgrade(version, nil) {
+			return true, false
+		}
+		if parent.RUnlock(parentVersion, &n.lock) {
+			return false, true
+		}
+	}
+	// the generation is checked once the node is locked, a snapshot taken
+	// afterwards waits for the lock before reading the node
+	if n.epoch.isFrozen() {
+		n.lock.Unlock()
+		if lockParent {
+			parent.Unlock()
+		}
+		return false, true
+	}
+	return false, false
+}
+
+// removeAt removes the child at idx from the write locked node and unlocks it. If collapse is true
+// the node is replaced in its write locked parent by the only entry left in it, and the parent is unlocked too.
+func (n *inner[T]) removeAt(idx int, collapse bool, parent *olock, parentUpdate func(node[T])) (removed node[T]) {
+	if collapse {
+		removed = n.node.replace(idx, nil)
+		n.collapse(parentUpdate)
+		parent.Unlock()
+		return removed
+	}
+	removed = n.drop(idx)
+	n.lock.Unlock()
+	return removed
+}
+
+// drop removes the child at idx from the write locked node, which is shrunk if it gets too small.
+// The node must keep at least two entries.
+func (n *inner[T]) drop(idx int) (removed node[T]) {
+	_, isNode4 := n.node.(*node4[T])
+	min := n.node.min()
+	removed = n.node.replace(idx, nil)
+	if min && !isNode4 {
+		n.node = n.node.shrink()
+	}
+	return removed
+}
+
+// applyLocked applies fn to the leaf of key in the write locked node, the first depth bytes of
+// key are the path of the node. fn may delete the key only if del is true. It returns false,
+// without calling fn, if applying fn might need more than the node: the key belongs to an inner
+// child or diverges from the prefix of the node, or its removal might collapse the node.
+func (n *inner[T]) applyLocked(key Key, depth int, del bool, fn func(*leaf[T]) (T, Action)) (delta int, ok bool) {
+	mismatch := n.prefixMismatch(key, depth)
+	if mismatch < n.prefixLen {
+		return 0, false
+	}
+	nextDepth := depth + n.prefixLen
+	if len(key) == nextDepth {
+		if del && n.leaf != nil && n.node.size() == 1 {
+			return 0, false
+		}
+	} else {
+		_, next := n.node.child(key[nextDepth])
+		if _, ok := next.(*inner[T]); ok {
+			return 0, false
+		}
+		if l, ok := next.(*leaf[T]); del && ok && l.cmp(key) && n.entries() == 2 {
+			return 0, false
+		}
+	}
+	delta, _ = n.apply(key, depth, mismatch, fn, nil)
+	return delta, true
+}
+
+// apply calls fn with the leaf of key in the write locked node, nil if the key is missing, and applies
+// the returned action. The node is at depth and its prefix mismatches key at mismatch: the key diverges
+// from the prefix, and storing it splits the node whose parent must be write locked, or the key terminates
+// at the node, or it belongs to a child slot holding a leaf or nothing. Removing the key from a node with
+// two entries calls collapse to replace the node by the entry left, ok is what collapse returns.
+func (n *inner[T]) apply(key Key, depth, mismatch int, fn func(*leaf[T]) (T, Action), collapse func() bool) (delta int, ok bool) {
+	if mismatch < n.prefixLen {
+		if value, action := fn(nil); action == Store {
+			n.split(&leaf[T]{key: key, value: value}, depth, mismatch)
+			return 1, true
+		}
+		return 0, true
+	}
+
+	nextDepth := depth + n.prefixLen
+	if len(key) == nextDepth {
+		old := n.leaf
+		value, action := fn(old)
+		switch {
+		case action == Store:
+			n.leaf = &leaf[T]{key: key, value: value}
+			if old == nil {
+				delta = 1
+			}
+		case action == Delete && old != nil:
+			n.leaf = nil
+			if n.node.size() == 1 {
+				return -1, collapse()
+			}
+			delta = -1
+		}
+		return delta, true
+	}
+
+	idx, next := n.node.child(key[nextDepth])
+	other, isLeaf := next.(*leaf[T])
+	var old *leaf[T]
+	if isLeaf && other.cmp(key) {
+		old = other
+	}
+	value, action := fn(old)
+	switch {
+	case action == Store && old != nil:
+		n.node.replace(idx, &leaf[T]{key: key, value: value})
+	case action == Store && isLeaf:
+		// the leaf child is expanded into a node holding both keys
+		expanded, _, _ := other.insert(&leaf[T]{key: key, value: value}, nextDepth+1, &n.lock, 0)
+		expanded.(*inner[T]).epoch = n.epoch
+		n.node.replace(idx, expanded)
+		delta = 1
+	case action == Store:
+		n.addLeaf(&leaf[T]{key: key, value: value}, nextDepth)
+		delta = 1
+	case action == Delete && old != nil:
+		if n.entries() == 2 {
+			n.node.replace(idx, nil)
+			return -1, collapse()
+		}
+		n.drop(idx)
+		delta = -1
+	}
+	return delta, true
+}
+
+// collapse replaces the node in its parent with the only entry left in it,
+// which is either the leaf of the node or its single child.
+// Both the node and its parent must be write locked, the node is unlocked as obsolete.
+func (n *inner[T]) collapse(parentUpdate func(node[T])) {
+	var (
+		locked *inner[T]
+		frozen bool
+	)
+	entry, _ := n.remainder(func(child *inner[T], shared bool) bool {
+		child.lock.Lock()
+		locked, frozen = child, shared
+		return true
+	})
+	parentUpdate(entry)
+	if locked != nil && frozen {
+		// the frozen child is replaced by its copy
+		locked.lock.UnlockObsolete()
+	} else if locked != nil {
+		locked.lock.Unlock()
+	}
+	// n is unlinked from the tree, readers and writers still holding it must restart
+	n.lock.UnlockObsolete()
+}
+
+// remainder returns the only entry left in the write locked node, which replaces the node in its parent.
+// A single inner child gets the prefix of the node, it's write locked by lock first, ok is false if lock
+// fails. frozen is true if the child is shared with a snapshot, the entry is then a copy of the child
+// which gets the prefix, the child itself doesn't change. Readers of a child changed in place notice it.
+func (n *inner[T]) remainder(lock func(child *inner[T], frozen bool) bool) (entry node[T], ok bool) {
+	if n.leaf != nil {
+		return n.leaf, true
+	}
+	b, child := n.node.next(nil)
+	c, isInner := child.(*inner[T])
+	if !isInner {
+		return child, true
+	}
+	frozen := c.epoch != n.epoch
+	// the child is copied once locked, a writer which locked it before the snapshot may still change it
+	if !lock(c, frozen) {
+		return nil, false
+	}
+	if frozen {
+		c = c.clone(n.epoch)
+	}
+	c.addPrefixBefore(n, b)
+	return c, true
+}
+
+// delPrefix unlinks the child of the node covering prefix, the prefix must be longer than the prefix of the node.
+// The unlinked child is either an inner node whose keys all start with prefix, or a single leaf.
+// unlink is called with the child before it's unlinked, while the node is write locked, unless it's nil.
+func (n *inner[T]) delPrefix(prefix Key, depth int, parent *olock, parentVersion uint64, parentUpdate func(node[T]), unlink func(node[T])) (detached node[T], restart bool) {
+	for {
+		version, obsolete := n.lock.RLock()
+		if obsolete || n.epoch.isFrozen() {
+			return nil, true
+		}
+
+		nextDepth := depth + n.prefixLen
+		var (
+			idx     int
+			next    node[T]
+			covered bool
+		)
+		if n.prefixMismatch(prefix, depth) >= n.prefixLen {
+			idx, next = n.node.child(prefix[nextDepth])
+		}
+		switch child := next.(type) {
+		case *leaf[T]:
+			covered = bytes.HasPrefix(child.key, prefix)
+		case *inner[T]:
+			childDepth := nextDepth + 1
+			if childDepth+child.prefixLen < len(prefix) {
+				if parent.RUnlock(parentVersion, nil) {
+					return nil, true
+				}
+				if c, ok := n.frozen(child); ok {
+					if n.lock.Upgrade(version, nil) {
+						continue
+					}
+					n.thaw(idx, c)
+					n.lock.Unlock()
+					continue
+				}
+				if detached, restart = child.delPrefix(prefix, childDepth, &n.lock, version, func(rn node[T]) {
+					n.node.replace(idx, rn)
+				}, unlink); restart {
+					continue
+				}
+				return detached, false
+			}
+			covered = child.prefixMismatch(prefix, childDepth) >= len(prefix)-childDepth
+		}
+
+		if !covered {
+			// none of the keys starts with prefix, check for concurrent writes and exit
+			if n.lock.RUnlock(version, nil) {
+				continue
+			}
+			return nil, parent.RUnlock(parentVersion, nil)
+		}
+		collapse := n.entries() == 2
+		if retry, restart := n.upgrade(version, parent, parentVersion, collapse); restart {
+			return nil, true
+		} else if retry {
+			continue
+		}
+		if unlink != nil {
+			unlink(next)
+		}
+		return n.removeAt(idx, collapse, parent, parentUpdate), false
+	}
+}
+
+// drain marks all the inner nodes of an unlinked subtree obsolete, so that
+// concurrent operations still holding them restart. It waits for the writers
+// which have already locked a node and returns the number of leaves in the subtree,
+// deleted is called with every leaf unless it's nil.
+func drain[T any](n node[T], deleted func(*leaf[T])) (leaves int) {
+	current, ok := n.(*inner[T])
+	if !ok {
+		if deleted != nil {
+			deleted(n.(*leaf[T]))
+		}
+		return 1
+	}
+	current.lock.Lock()
+	children := make([]node[T], 0, current.node.size()+1)
+	if current.leaf != nil {
+		children = append(children, current.leaf)
+	}
+	for b, child := current.node.next(nil); child != nil; b, child = current.node.next(&b) {
+		children = append(children, child)
+	}
+	current.lock.UnlockObsolete()
+
+	for _, child := range children {
+		leaves += drain(child, deleted)
+	}
+	return leaves
+}
+
 // checkPrefix Returns the number of prefix characters shared between
 // the key and node.
 func (n *inner[T]) checkPrefix(key Key, depth int) int {
@@ -293,7 +780,18 @@ func (n *inner[T]) get(key Key, depth int, parent *olock, parentVersion uint64)
 		}
 
 		nextDepth := depth + n.prefixLen
-		_, next := n.node.child(key.At(nextDepth))
+		if len(key) <= nextDepth {
+			// the key terminates at this node
+			l := n.leaf
+			if n.lock.RUnlock(version, nil) {
+				continue
+			}
+			if l != nil && l.cmp(key) {
+				return l.value, true, false
+			}
+			return value, false, false
+		}
+		_, next := n.node.child(key[nextDepth])
 
 		if next == nil {
 			if n.lock.RUnlock(version, nil) {
@@ -316,9 +814,65 @@ func (n *inner[T]) get(key Key, depth int, parent *olock, parentVersion uint64)
 	}
 }
 
-func (n *inner[T]) walk(w walkFn[T], i int) bool {
-	//TODO implement me
-	panic("implement me")
+// longestPrefix returns the leaf of the longest key in the node which is a prefix of key.
+func (n *inner[T]) longestPrefix(key Key, depth int, parent *olock, parentVersion uint64) (match *leaf[T], restart bool) {
+	for {
+		version, obsolete := n.lock.RLock()
+		if obsolete || parent.RUnlock(parentVersion, nil) {
+			return nil, true
+		}
+		prefixLen := n.checkPrefix(key, depth)
+		nextDepth := depth + n.prefixLen
+		if prefixLen != min(n.prefixLen, maxPrefixLen) || len(key) < nextDepth {
+			// all the keys in the node are longer than key or diverge from it
+			if n.lock.RUnlock(version, nil) {
+				continue
+			}
+			return nil, false
+		}
+
+		// the leaf of the node is shorter than the keys in the children
+		match = nil
+		if l := n.leaf; l != nil && bytes.HasPrefix(key, l.key) {
+			match = l
+		}
+		var next node[T]
+		if len(key) > nextDepth {
+			_, next = n.node.child(key[nextDepth])
+		}
+		switch child := next.(type) {
+		case *leaf[T]:
+			if bytes.HasPrefix(key, child.key) {
+				match = child
+			}
+		case *inner[T]:
+			longer, restart := child.longestPrefix(key, nextDepth+1, &n.lock, version)
+			if restart {
+				continue
+			}
+			if longer != nil {
+				return longer, false
+			}
+			return match, false
+		}
+		if n.lock.RUnlock(version, nil) {
+			continue
+		}
+		return match, false
+	}
+}
+
+// walk visits the node before its leaf and its children, which are one level deeper.
+// The node isn't read while it's locked, the nodes of a snapshot are stable once unlocked.
+func (n *inner[T]) walk(fn walkFn[T], depth int) bool {
+	n.lock.wait()
+	if !fn(n, depth) {
+		return false
+	}
+	if n.leaf != nil && !n.leaf.walk(fn, depth+1) {
+		return false
+	}
+	return n.node.walk(fn, depth+1)
 }
 
 func memcpy[T any](dst []T, src []T, len int) {
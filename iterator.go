@@ -5,164 +5,160 @@ import (
 )
 
 type checkpoint[T any] struct {
-	node          *inner[T]
-	parentLock    *olock
-	parentVersion uint64
-	pointer       *byte
+	node    *inner[T]
+	pointer *byte
+	// leaf is true if the leaf of the node is on the path instead of a child
+	leaf bool
+	// version of the node when the checkpoint was read
+	version uint64
 
 	prev *checkpoint[T]
 }
 
+// Bound is one end of a Range.
+type Bound struct {
+	Key       []byte
+	Inclusive bool
+}
+
+// Included returns a bound including key in the range.
+func Included(key []byte) *Bound {
+	return &Bound{Key: key, Inclusive: true}
+}
+
+// Excluded returns a bound excluding key from the range.
+func Excluded(key []byte) *Bound {
+	return &Bound{Key: key}
+}
+
+// Range is the set of keys between the Lower and the Upper bounds,
+// a nil bound leaves the range open on its side.
+type Range struct {
+	Lower, Upper *Bound
+}
+
+// startEnd returns the range (start, end], an empty bound leaves the range open.
+func startEnd(start, end []byte) (r Range) {
+	if len(start) > 0 {
+		r.Lower = Excluded(start)
+	}
+	if len(end) > 0 {
+		r.Upper = Included(end)
+	}
+	return r
+}
+
 // iterator will scan the tree in lexicographic order.
 type iterator[T any] struct {
-	tree *Tree[T]
-
-	stack  *checkpoint[T]
-	closed bool
+	cursor *Cursor[T]
 
-	cursor, terminate []byte
-	reverse           bool
+	rng     Range
+	reverse bool
+	// flip swaps the inclusion of the bounds on Reverse, the iteration
+	// of (start, end] turns into [start, end) in reverse.
+	flip bool
+	// prefix limits the iteration to the keys starting with it
+	prefix []byte
 
-	key   []byte
-	value T
+	started, closed bool
 }
 
+// Reverse turns the iteration from the end of the range to its start, it does nothing once the iterator is reversed.
 func (i *iterator[T]) Reverse() *iterator[T] {
-	i.cursor, i.terminate = i.terminate, i.cursor
+	if i.reverse {
+		return i
+	}
 	i.reverse = true
+	if i.flip {
+		for _, b := range []*Bound{i.rng.Lower, i.rng.Upper} {
+			if b != nil {
+				b.Inclusive = !b.Inclusive
+			}
+		}
+	}
 	return i
 }
 
-// Next will iterate over all leaf nodes between specified prefixes
+// Next will iterate over all leaf nodes in the range
 func (i *iterator[T]) Next() bool {
 	if i.closed {
 		return false
 	}
-	if i.stack == nil {
-		// initialize iterator
-		if exit, next := i.init(); exit {
-			return next
-		}
+	var more bool
+	switch {
+	case !i.started:
+		i.started = true
+		more = i.seek()
+	case !i.reverse:
+		more = i.cursor.Next()
+	default:
+		more = i.cursor.Prev()
 	}
-	return i.iterate()
+	if !more || !i.within(i.cursor.Key()) {
+		i.closed = true
+		return false
+	}
+	return true
 }
 
 func (i *iterator[T]) Value() T {
-	return i.value
+	return i.cursor.Value()
 }
 
 func (i *iterator[T]) Key() Key {
-	return i.key
+	return i.cursor.Key()
 }
 
-func (i *iterator[T]) inRange(key []byte) bool {
+// seek moves the cursor straight to the first key of the iteration,
+// which is bounded by both the range and the prefix.
+func (i *iterator[T]) seek() bool {
+	c := i.cursor
+	c.prefix = i.prefix
 	if !i.reverse {
-		return bytes.Compare(key, i.cursor) > 0 && (len(i.terminate) == 0 || bytes.Compare(key, i.terminate) <= 0)
-	}
-	return (bytes.Compare(key, i.cursor) < 0 || len(i.cursor) == 0) && (len(i.terminate) == 0 || bytes.Compare(key, i.terminate) >= 0)
-}
-
-func (i *iterator[T]) init() (bool, bool) {
-	for {
-		version, _ := i.tree.lock.RLock()
-
-		root := i.tree.root
-		if root == nil {
-			if i.tree.lock.RUnlock(version, nil) {
-				continue
-			}
-			i.closed = true
-			return true, false
+		if from := i.rng.Lower; from != nil && bytes.Compare(from.Key, i.prefix) >= 0 {
+			c.seek(from.Key, true, from.Inclusive)
+		} else {
+			c.seek(i.prefix, true, true)
 		}
-		l, isLeaf := root.(*leaf[T])
-		if isLeaf {
-			if i.tree.lock.RUnlock(version, nil) {
-				continue
-			}
-			i.closed = true
-			if i.inRange(l.key) {
-				i.key = l.key
-				i.value = l.value
-				return true, true
-			}
-			return true, false
-		}
-		i.stack = &checkpoint[T]{
-			node:          root.(*inner[T]),
-			parentLock:    &i.tree.lock,
-			parentVersion: version,
+		return c.valid
+	}
+	// the keys starting with the prefix are before its successor
+	from, end := i.rng.Upper, successor(i.prefix)
+	switch {
+	case from != nil && (end == nil || bytes.Compare(from.Key, end) < 0):
+		c.seek(from.Key, false, from.Inclusive)
+	case end != nil:
+		c.seek(end, false, false)
+	default:
+		for c.edge(false) {
 		}
-		return false, false
+		c.bound()
 	}
+	return c.valid
 }
 
-func (i *iterator[T]) next(n *inner[T], pointer *byte) (byte, node[T]) {
-	if !i.reverse {
-		return n.node.next(pointer)
+// within checks the key against the bound the iteration ends at.
+func (i *iterator[T]) within(key []byte) bool {
+	if !i.reverse && i.rng.Upper != nil {
+		cmp := bytes.Compare(key, i.rng.Upper.Key)
+		return cmp < 0 || cmp == 0 && i.rng.Upper.Inclusive
 	}
-	return n.node.prev(pointer)
-}
-
-func (i *iterator[T]) iterate() bool {
-	for i.stack != nil {
-		more, restart := i.tryAdvance()
-		if more {
-			return more
-		} else if restart {
-			i.stack = i.stack.prev
-			if i.stack == nil {
-				// checkpoint is root
-				i.stack = nil
-				if exit, next := i.init(); exit {
-					return next
-				}
-			}
-		}
+	if i.reverse && i.rng.Lower != nil {
+		cmp := bytes.Compare(key, i.rng.Lower.Key)
+		return cmp > 0 || cmp == 0 && i.rng.Lower.Inclusive
 	}
-	i.closed = true
-	return false
+	return true
 }
 
-func (i *iterator[T]) tryAdvance() (bool, bool) {
-	for {
-		tail := i.stack
-
-		version, obsolete := tail.node.lock.RLock()
-		if obsolete || tail.parentLock.Check(tail.parentVersion) {
-			_ = tail.parentLock.RUnlock(version, nil)
-			return false, true
-		}
-
-		pointer, child := i.next(tail.node, tail.pointer)
-
-		if child == nil {
-			if tail.node.lock.RUnlock(version, nil) {
-				continue
-			}
-			_ = tail.parentLock.RUnlock(version, nil)
-			// inner node is exhausted, move one level up the stack
-			i.stack = tail.prev
-			return false, false
-		}
-		// advance pointer
-		tail.pointer = &pointer
-
-		l, isLeaf := child.(*leaf[T])
-		if isLeaf {
-			if i.inRange(l.key) {
-				i.key = l.key
-				i.value = l.value
-				i.cursor = l.key
-				return true, false
-			}
-			return false, false
-		}
-		i.stack = &checkpoint[T]{
-			node:          child.(*inner[T]),
-			prev:          tail,
-			parentLock:    &tail.node.lock,
-			parentVersion: version,
+// successor returns the smallest key after all the keys starting with prefix,
+// or nil if there is none.
+func successor(prefix []byte) []byte {
+	for n := len(prefix); n > 0; n-- {
+		if prefix[n-1] != 0xff {
+			end := append([]byte(nil), prefix[:n]...)
+			end[n-1]++
+			return end
 		}
-		return false, false
 	}
+	return nil
 }
@@ -1,7 +1,10 @@
 package art
 
 import (
+	"fmt"
+	"math/rand"
 	"sort"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -119,6 +122,14 @@ func TestIterator(t *testing.T) {
 				rst = append(rst, iter.Value())
 			}
 			require.Equal(t, tc.rst, rst)
+			if tc.reverse {
+				// reversing again changes neither the direction nor the bounds
+				rst = []string{}
+				for iter := tree.Iterator([]byte(tc.start), []byte(tc.end)).Reverse().Reverse(); iter.Next(); {
+					rst = append(rst, iter.Value())
+				}
+				require.Equal(t, tc.rst, rst)
+			}
 		})
 	}
 }
@@ -145,3 +156,324 @@ func TestIterConcurrentExpansion(t *testing.T) {
 	require.True(t, iter.Next())
 	require.Equal(t, Key("aaca"), iter.Key())
 }
+
+func TestPrefixIterator(t *testing.T) {
+	keys := []string{
+		"tenant/1/a",
+		"tenant/1/b",
+		"tenant/12/a",
+		"tenant/2/a",
+		"tenant/2/b/c",
+		"tenant/2/b/d",
+		"tenant",
+		"tenant/a very long namespace/1",
+		"tenant/a very long namespace/2",
+		"tenant/a very long namespace",
+		"user/1",
+	}
+	for _, tc := range []struct {
+		desc    string
+		keys    []string
+		prefix  string
+		reverse bool
+		rst     []string
+	}{
+		{
+			desc: "empty tree",
+			rst:  []string{},
+		},
+		{
+			desc:   "matching leaf",
+			keys:   keys[:1],
+			prefix: "tenant/1",
+			rst:    keys[:1],
+		},
+		{
+			desc:   "non matching leaf",
+			keys:   keys[:1],
+			prefix: "tenant/2",
+			rst:    []string{},
+		},
+		{
+			desc: "empty prefix",
+			keys: keys,
+			rst: []string{
+				"tenant", "tenant/1/a", "tenant/1/b", "tenant/12/a", "tenant/2/a", "tenant/2/b/c", "tenant/2/b/d",
+				"tenant/a very long namespace", "tenant/a very long namespace/1", "tenant/a very long namespace/2",
+				"user/1",
+			},
+		},
+		{
+			desc:   "prefix ends at a node",
+			keys:   keys,
+			prefix: "tenant/1/",
+			rst:    []string{"tenant/1/a", "tenant/1/b"},
+		},
+		{
+			desc:   "prefix ends inside the prefix of a node",
+			keys:   keys,
+			prefix: "tenant/2/b/",
+			rst:    []string{"tenant/2/b/c", "tenant/2/b/d"},
+		},
+		{
+			desc:   "prefix is a key",
+			keys:   keys,
+			prefix: "tenant/1",
+			rst:    []string{"tenant/1/a", "tenant/1/b", "tenant/12/a"},
+		},
+		{
+			desc:   "prefix equals to a key",
+			keys:   keys,
+			prefix: "tenant/2/b/c",
+			rst:    []string{"tenant/2/b/c"},
+		},
+		{
+			desc:   "long prefix",
+			keys:   keys,
+			prefix: "tenant/a very long",
+			rst:    []string{"tenant/a very long namespace", "tenant/a very long namespace/1", "tenant/a very long namespace/2"},
+		},
+		{
+			desc:   "long prefix mismatch",
+			keys:   keys,
+			prefix: "tenant/a very short",
+			rst:    []string{},
+		},
+		{
+			desc:   "prefix longer than keys",
+			keys:   keys,
+			prefix: "tenant/1/a/b",
+			rst:    []string{},
+		},
+		{
+			desc:   "no matching child",
+			keys:   keys,
+			prefix: "tenant/3",
+			rst:    []string{},
+		},
+		{
+			desc:    "reverse",
+			keys:    keys,
+			prefix:  "tenant/2",
+			reverse: true,
+			rst:     []string{"tenant/2/b/d", "tenant/2/b/c", "tenant/2/a"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			var tree Tree[string]
+			for _, key := range tc.keys {
+				tree.Insert([]byte(key), key)
+			}
+			iter := tree.PrefixIterator([]byte(tc.prefix))
+			if tc.reverse {
+				iter = iter.Reverse()
+			}
+			rst := []string{}
+			for iter.Next() {
+				rst = append(rst, iter.Value())
+			}
+			require.Equal(t, tc.rst, rst)
+
+			if tc.reverse {
+				return
+			}
+			rst = []string{}
+			tree.ScanPrefix([]byte(tc.prefix), func(key Key, value string) bool {
+				require.Equal(t, Key(value), key)
+				rst = append(rst, value)
+				return true
+			})
+			require.Equal(t, tc.rst, rst)
+		})
+	}
+}
+
+func TestScanPrefixTerminate(t *testing.T) {
+	var tree Tree[int]
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte(fmt.Sprintf("prefix::%03d", i)), i)
+	}
+	rst := []int{}
+	tree.ScanPrefix([]byte("prefix::"), func(key Key, value int) bool {
+		rst = append(rst, value)
+		return len(rst) < 3
+	})
+	require.Equal(t, []int{0, 1, 2}, rst)
+}
+
+func TestScanPrefixConcurrentWrites(t *testing.T) {
+	var (
+		tree Tree[int]
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	for i := 0; i < 1000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("tenant/1/%04d", i)), i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			// keys under other prefixes keep changing the shared nodes
+			key := []byte(fmt.Sprintf("tenant/%d/%04d", 2+i%10, i%1000))
+			if i%3 == 0 {
+				tree.Remove(key)
+			} else {
+				tree.Insert(key, i)
+			}
+		}
+	}()
+	for run := 0; run < 50; run++ {
+		expected := 0
+		tree.ScanPrefix([]byte("tenant/1/"), func(key Key, value int) bool {
+			require.Equal(t, []byte(fmt.Sprintf("tenant/1/%04d", expected)), []byte(key))
+			require.Equal(t, expected, value)
+			expected++
+			return true
+		})
+		require.Equal(t, 1000, expected)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRangeIterator(t *testing.T) {
+	var tree Tree[string]
+	keys := []string{"", "a", "ab", "abc", "b", "ba", "c"}
+	for _, key := range keys {
+		tree.Insert([]byte(key), key)
+	}
+	for _, tc := range []struct {
+		desc    string
+		rng     Range
+		forward []string
+	}{
+		{
+			desc:    "open",
+			forward: keys,
+		},
+		{
+			desc:    "inclusive",
+			rng:     Range{Lower: Included([]byte("ab")), Upper: Included([]byte("b"))},
+			forward: []string{"ab", "abc", "b"},
+		},
+		{
+			desc:    "exclusive",
+			rng:     Range{Lower: Excluded([]byte("ab")), Upper: Excluded([]byte("b"))},
+			forward: []string{"abc"},
+		},
+		{
+			desc:    "inclusive lower, exclusive upper",
+			rng:     Range{Lower: Included([]byte("ab")), Upper: Excluded([]byte("b"))},
+			forward: []string{"ab", "abc"},
+		},
+		{
+			desc:    "bounds between keys",
+			rng:     Range{Lower: Excluded([]byte("aa")), Upper: Included([]byte("bb"))},
+			forward: []string{"ab", "abc", "b", "ba"},
+		},
+		{
+			desc:    "open lower",
+			rng:     Range{Upper: Excluded([]byte("ab"))},
+			forward: []string{"", "a"},
+		},
+		{
+			desc:    "open upper",
+			rng:     Range{Lower: Excluded([]byte("ba"))},
+			forward: []string{"c"},
+		},
+		{
+			desc:    "empty key included",
+			rng:     Range{Lower: Included([]byte{}), Upper: Included([]byte("a"))},
+			forward: []string{"", "a"},
+		},
+		{
+			desc:    "empty key excluded",
+			rng:     Range{Lower: Excluded([]byte{}), Upper: Included([]byte("a"))},
+			forward: []string{"a"},
+		},
+		{
+			desc: "empty range",
+			rng:  Range{Lower: Excluded([]byte("b")), Upper: Excluded([]byte("ba"))},
+		},
+		{
+			desc: "inverted range",
+			rng:  Range{Lower: Included([]byte("c")), Upper: Included([]byte("a"))},
+		},
+	} {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			rst := []string{}
+			for iter := tree.RangeIterator(tc.rng); iter.Next(); {
+				rst = append(rst, iter.Value())
+			}
+			expected := append([]string{}, tc.forward...)
+			require.Equal(t, expected, rst)
+
+			rst = []string{}
+			for iter := tree.RangeIterator(tc.rng).Reverse(); iter.Next(); {
+				rst = append(rst, iter.Value())
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(expected)))
+			require.Equal(t, expected, rst)
+		})
+	}
+}
+
+func TestRangeIteratorProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	alphabet := []byte{0, 1, 'a', 'b', 255}
+	var tree Tree[int]
+	var keys []string
+	seen := map[string]bool{}
+	for i := 0; i < 3000; i++ {
+		key := randomBinaryKey(rng, alphabet)
+		tree.Insert(key, i)
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+
+	bound := func() *Bound {
+		if rng.Intn(5) == 0 {
+			return nil
+		}
+		return &Bound{Key: randomBinaryKey(rng, alphabet), Inclusive: rng.Intn(2) == 0}
+	}
+	for i := 0; i < 500; i++ {
+		r := Range{Lower: bound(), Upper: bound()}
+		var expected []string
+		for _, key := range keys {
+			if l := r.Lower; l != nil && (key < string(l.Key) || key == string(l.Key) && !l.Inclusive) {
+				continue
+			}
+			if u := r.Upper; u != nil && (key > string(u.Key) || key == string(u.Key) && !u.Inclusive) {
+				continue
+			}
+			expected = append(expected, key)
+		}
+		var rst []string
+		for iter := tree.RangeIterator(r); iter.Next(); {
+			rst = append(rst, string(iter.Key()))
+		}
+		require.Equal(t, expected, rst)
+
+		rst = rst[:0]
+		for iter := tree.RangeIterator(r).Reverse(); iter.Next(); {
+			rst = append([]string{string(iter.Key())}, rst...)
+		}
+		if len(expected) == 0 {
+			require.Empty(t, rst)
+		} else {
+			require.Equal(t, expected, rst)
+		}
+	}
+}
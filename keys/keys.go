@@ -0,0 +1,233 @@
+// Package keys encodes values to bytes which sort in the same order as the
+// values, to build the keys of a tree out of integers, floats, strings, times
+// and tuples of them.
+//
+// The encoders append to a slice, several values appended one after the other
+// form a tuple, which sorts by its first value, then by the second one and so on.
+// The decoders return the bytes left after the value they decode.
+package keys
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrTruncated is returned when the bytes end before the end of the value.
+	ErrTruncated = errors.New("keys: truncated value")
+	// ErrInvalid is returned when the bytes are not an encoded value.
+	ErrInvalid = errors.New("keys: invalid value")
+)
+
+const (
+	escape     byte = 0x00
+	terminator byte = 0x01
+	escaped    byte = 0xff
+)
+
+// AppendUint64 appends v in 8 bytes, big endian.
+func AppendUint64(dst []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, v)
+}
+
+// DecodeUint64 decodes a value of AppendUint64.
+func DecodeUint64(b []byte) (uint64, []byte, error) {
+	if len(b) < 8 {
+		return 0, b, ErrTruncated
+	}
+	return binary.BigEndian.Uint64(b), b[8:], nil
+}
+
+// AppendUint32 appends v in 4 bytes, big endian.
+func AppendUint32(dst []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(dst, v)
+}
+
+// DecodeUint32 decodes a value of AppendUint32.
+func DecodeUint32(b []byte) (uint32, []byte, error) {
+	if len(b) < 4 {
+		return 0, b, ErrTruncated
+	}
+	return binary.BigEndian.Uint32(b), b[4:], nil
+}
+
+// AppendInt64 appends v in 8 bytes, with the sign bit flipped so that
+// the negative values sort before the positive ones.
+func AppendInt64(dst []byte, v int64) []byte {
+	return AppendUint64(dst, uint64(v)^1<<63)
+}
+
+// DecodeInt64 decodes a value of AppendInt64.
+func DecodeInt64(b []byte) (int64, []byte, error) {
+	v, rest, err := DecodeUint64(b)
+	if err != nil {
+		return 0, b, err
+	}
+	return int64(v ^ 1<<63), rest, nil
+}
+
+// AppendInt32 appends v in 4 bytes, like AppendInt64.
+func AppendInt32(dst []byte, v int32) []byte {
+	return AppendUint32(dst, uint32(v)^1<<31)
+}
+
+// DecodeInt32 decodes a value of AppendInt32.
+func DecodeInt32(b []byte) (int32, []byte, error) {
+	v, rest, err := DecodeUint32(b)
+	if err != nil {
+		return 0, b, err
+	}
+	return int32(v ^ 1<<31), rest, nil
+}
+
+// AppendFloat64 appends v in 8 bytes. The positive values have their sign bit flipped,
+// the negative ones have all their bits flipped, so that the larger magnitudes sort first.
+//
+// -0 sorts before +0 and both are kept. A NaN with the sign bit cleared, like math.NaN,
+// sorts after +Inf.
+func AppendFloat64(dst []byte, v float64) []byte {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 63
+	}
+	return AppendUint64(dst, bits)
+}
+
+// DecodeFloat64 decodes a value of AppendFloat64.
+func DecodeFloat64(b []byte) (float64, []byte, error) {
+	bits, rest, err := DecodeUint64(b)
+	if err != nil {
+		return 0, b, err
+	}
+	if bits&(1<<63) != 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), rest, nil
+}
+
+// AppendFloat32 appends v in 4 bytes, like AppendFloat64.
+func AppendFloat32(dst []byte, v float32) []byte {
+	bits := math.Float32bits(v)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 31
+	}
+	return AppendUint32(dst, bits)
+}
+
+// DecodeFloat32 decodes a value of AppendFloat32.
+func DecodeFloat32(b []byte) (float32, []byte, error) {
+	bits, rest, err := DecodeUint32(b)
+	if err != nil {
+		return 0, b, err
+	}
+	if bits&(1<<31) != 0 {
+		bits ^= 1 << 31
+	} else {
+		bits = ^bits
+	}
+	return math.Float32frombits(bits), rest, nil
+}
+
+// AppendBool appends false as 0 and true as 1.
+func AppendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// DecodeBool decodes a value of AppendBool.
+func DecodeBool(b []byte) (bool, []byte, error) {
+	if len(b) == 0 {
+		return false, b, ErrTruncated
+	}
+	switch b[0] {
+	case 0:
+		return false, b[1:], nil
+	case 1:
+		return true, b[1:], nil
+	}
+	return false, b, ErrInvalid
+}
+
+// AppendBytes appends v followed by the terminator 0x00 0x01. The zero bytes of v are escaped
+// as 0x00 0xff, so that v sorts before all the values it's a prefix of, whatever follows it.
+func AppendBytes(dst []byte, v []byte) []byte {
+	for _, c := range v {
+		if c == escape {
+			dst = append(dst, escape, escaped)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, escape, terminator)
+}
+
+// DecodeBytes decodes a value of AppendBytes, the returned slice is a new one.
+func DecodeBytes(b []byte) ([]byte, []byte, error) {
+	v := []byte{}
+	for i := 0; i < len(b); i++ {
+		if b[i] != escape {
+			v = append(v, b[i])
+			continue
+		}
+		if i+1 == len(b) {
+			return nil, b, ErrTruncated
+		}
+		switch b[i+1] {
+		case terminator:
+			return v, b[i+2:], nil
+		case escaped:
+			v = append(v, escape)
+			i++
+		default:
+			return nil, b, ErrInvalid
+		}
+	}
+	return nil, b, ErrTruncated
+}
+
+// AppendString appends v like AppendBytes.
+func AppendString(dst []byte, v string) []byte {
+	return AppendBytes(dst, []byte(v))
+}
+
+// DecodeString decodes a value of AppendString.
+func DecodeString(b []byte) (string, []byte, error) {
+	v, rest, err := DecodeBytes(b)
+	if err != nil {
+		return "", b, err
+	}
+	return string(v), rest, nil
+}
+
+// AppendTime appends the instant of v in 12 bytes, the seconds since the Unix epoch
+// like AppendInt64, followed by the nanoseconds. The location and the monotonic
+// clock reading of v are not kept.
+func AppendTime(dst []byte, v time.Time) []byte {
+	return AppendUint32(AppendInt64(dst, v.Unix()), uint32(v.Nanosecond()))
+}
+
+// DecodeTime decodes a value of AppendTime, the time is in UTC.
+func DecodeTime(b []byte) (time.Time, []byte, error) {
+	sec, rest, err := DecodeInt64(b)
+	if err != nil {
+		return time.Time{}, b, err
+	}
+	nsec, rest, err := DecodeUint32(rest)
+	if err != nil {
+		return time.Time{}, b, err
+	}
+	if nsec >= uint32(time.Second) {
+		return time.Time{}, b, ErrInvalid
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), rest, nil
+}
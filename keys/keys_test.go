@@ -0,0 +1,188 @@
+package keys_test
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	art "github.com/WenyXu/sync-adaptive-radix-tree"
+	"github.com/WenyXu/sync-adaptive-radix-tree/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkOrder inserts the encoded values in a tree and checks that iterating it
+// returns the values in order, and that every value is decoded back.
+func checkOrder[V any](t *testing.T, values []V, enc func([]byte, V) []byte, dec func([]byte) (V, []byte, error), less func(a, b V) bool, equal func(a, b V) bool) {
+	t.Helper()
+	tree := art.Tree[int]{}
+	for i, v := range values {
+		encoded := enc(nil, v)
+		decoded, rest, err := dec(encoded)
+		require.NoError(t, err)
+		require.Empty(t, rest)
+		require.Truef(t, equal(v, decoded), "decoded %v as %v", v, decoded)
+		tree.Insert(encoded, i)
+	}
+	var previous *V
+	for iter := tree.Iterator(nil, nil); iter.Next(); {
+		v := values[iter.Value()]
+		if previous != nil {
+			require.Falsef(t, less(v, *previous), "%v sorted after %v", *previous, v)
+		}
+		previous = &v
+	}
+}
+
+func TestIntegers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	signed := []int64{math.MinInt64, math.MinInt64 + 1, -256, -1, 0, 1, 255, 256, math.MaxInt64}
+	unsigned := []uint64{0, 1, 255, 256, math.MaxUint32, math.MaxUint64}
+	for i := 0; i < 1000; i++ {
+		signed = append(signed, rng.Int63()-rng.Int63(), int64(rng.Intn(1000)-500))
+		unsigned = append(unsigned, rng.Uint64(), uint64(rng.Intn(1000)))
+	}
+	checkOrder(t, signed, keys.AppendInt64, keys.DecodeInt64, func(a, b int64) bool { return a < b }, func(a, b int64) bool { return a == b })
+	checkOrder(t, unsigned, keys.AppendUint64, keys.DecodeUint64, func(a, b uint64) bool { return a < b }, func(a, b uint64) bool { return a == b })
+
+	signed32 := make([]int32, len(signed))
+	unsigned32 := make([]uint32, len(unsigned))
+	for i, v := range signed {
+		signed32[i] = int32(v)
+	}
+	for i, v := range unsigned {
+		unsigned32[i] = uint32(v)
+	}
+	checkOrder(t, signed32, keys.AppendInt32, keys.DecodeInt32, func(a, b int32) bool { return a < b }, func(a, b int32) bool { return a == b })
+	checkOrder(t, unsigned32, keys.AppendUint32, keys.DecodeUint32, func(a, b uint32) bool { return a < b }, func(a, b uint32) bool { return a == b })
+}
+
+func TestFloats(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	values := []float64{math.Inf(-1), -math.MaxFloat64, -1, -math.SmallestNonzeroFloat64, math.Copysign(0, -1), 0,
+		math.SmallestNonzeroFloat64, 1, math.MaxFloat64, math.Inf(1)}
+	for len(values) < 2000 {
+		if v := math.Float64frombits(rng.Uint64()); !math.IsNaN(v) {
+			values = append(values, v, rng.NormFloat64())
+		}
+	}
+	equal := func(a, b float64) bool { return math.Float64bits(a) == math.Float64bits(b) }
+	checkOrder(t, values, keys.AppendFloat64, keys.DecodeFloat64, func(a, b float64) bool { return a < b }, equal)
+
+	values32 := make([]float32, len(values))
+	for i, v := range values {
+		values32[i] = float32(v)
+	}
+	checkOrder(t, values32, keys.AppendFloat32, keys.DecodeFloat32, func(a, b float32) bool { return a < b }, func(a, b float32) bool {
+		return math.Float32bits(a) == math.Float32bits(b)
+	})
+
+	// -0 before +0, NaN after +Inf
+	assert.Equal(t, -1, bytes.Compare(keys.AppendFloat64(nil, math.Copysign(0, -1)), keys.AppendFloat64(nil, 0)))
+	assert.Equal(t, 1, bytes.Compare(keys.AppendFloat64(nil, math.NaN()), keys.AppendFloat64(nil, math.Inf(1))))
+	nan, _, err := keys.DecodeFloat64(keys.AppendFloat64(nil, math.NaN()))
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(nan))
+}
+
+func TestStrings(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	alphabet := []byte{0x00, 0x01, 0x02, 'a', 0xfe, 0xff}
+	values := []string{"", "\x00", "\x00\x00", "\x00\x01", "\x01", "a", "a\x00", "a\x00a", "a\x01", "aa", "\xff"}
+	for i := 0; i < 2000; i++ {
+		b := make([]byte, rng.Intn(6))
+		for j := range b {
+			b[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		values = append(values, string(b))
+	}
+	checkOrder(t, values, keys.AppendString, keys.DecodeString, func(a, b string) bool { return a < b }, func(a, b string) bool { return a == b })
+
+	_, _, err := keys.DecodeString([]byte("a"))
+	assert.ErrorIs(t, err, keys.ErrTruncated)
+	_, _, err = keys.DecodeString([]byte("a\x00"))
+	assert.ErrorIs(t, err, keys.ErrTruncated)
+	_, _, err = keys.DecodeString([]byte("a\x00\x02"))
+	assert.ErrorIs(t, err, keys.ErrInvalid)
+}
+
+func TestTimes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	values := []time.Time{time.Unix(0, 0), time.Unix(-1, 999999999), time.Unix(-1, 0), time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC)}
+	for i := 0; i < 2000; i++ {
+		// within a few seconds of each other, or spread over centuries
+		values = append(values, time.Unix(int64(rng.Intn(10)-5), int64(rng.Intn(int(time.Second)))),
+			time.Unix(rng.Int63n(1<<36)-1<<35, int64(rng.Intn(int(time.Second)))))
+	}
+	// the location isn't kept, the instant is
+	values = append(values, time.Unix(100, 1).In(time.FixedZone("east", 3600)))
+	checkOrder(t, values, keys.AppendTime, keys.DecodeTime, time.Time.Before, time.Time.Equal)
+}
+
+type row struct {
+	id    int64
+	name  string
+	score float64
+}
+
+func TestTuples(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	names := []string{"", "a", "a\x00", "ab", "b"}
+	rows := make([]row, 2000)
+	for i := range rows {
+		rows[i] = row{id: int64(rng.Intn(5) - 2), name: names[rng.Intn(len(names))], score: float64(rng.Intn(5)) - 2.5}
+	}
+	enc := func(dst []byte, r row) []byte {
+		dst, err := keys.AppendTuple(dst, r.id, r.name, r.score)
+		require.NoError(t, err)
+		return dst
+	}
+	dec := func(b []byte) (r row, rest []byte, err error) {
+		rest, err = keys.Scan(b, &r.id, &r.name, &r.score)
+		return r, rest, err
+	}
+	less := func(a, b row) bool {
+		if a.id != b.id {
+			return a.id < b.id
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.score < b.score
+	}
+	checkOrder(t, rows, enc, dec, less, func(a, b row) bool { return a == b })
+}
+
+func TestTuple_Types(t *testing.T) {
+	now := time.Now()
+	encoded, err := keys.Tuple(1, int64(2), int32(3), uint(4), uint64(5), uint32(6), 7.5, float32(8.5), true, "9", []byte("10"), now)
+	require.NoError(t, err)
+	var (
+		i   int
+		i64 int64
+		i32 int32
+		u   uint
+		u64 uint64
+		u32 uint32
+		f64 float64
+		f32 float32
+		b   bool
+		s   string
+		bs  []byte
+		tm  time.Time
+	)
+	rest, err := keys.Scan(append(encoded, "rest"...), &i, &i64, &i32, &u, &u64, &u32, &f64, &f32, &b, &s, &bs, &tm)
+	require.NoError(t, err)
+	assert.Equal(t, "rest", string(rest))
+	assert.Equal(t, []any{1, int64(2), int32(3), uint(4), uint64(5), uint32(6), 7.5, float32(8.5), true, "9", []byte("10")},
+		[]any{i, i64, i32, u, u64, u32, f64, f32, b, s, bs})
+	assert.True(t, now.Equal(tm))
+
+	_, err = keys.Tuple(struct{}{})
+	assert.Error(t, err)
+	_, err = keys.Scan(encoded[:10], &i, &i64)
+	assert.ErrorIs(t, err, keys.ErrTruncated)
+}
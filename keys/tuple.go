@@ -0,0 +1,93 @@
+package keys
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tuple encodes values one after the other, the tuples sort by their first value,
+// then by the second one and so on. The values are int, int64, int32, uint, uint64,
+// uint32, float64, float32, bool, string, []byte or time.Time, int and uint are
+// encoded as int64 and uint64.
+func Tuple(values ...any) ([]byte, error) {
+	return AppendTuple(nil, values...)
+}
+
+// AppendTuple appends the encoding of values to dst, see Tuple.
+func AppendTuple(dst []byte, values ...any) ([]byte, error) {
+	for _, value := range values {
+		switch v := value.(type) {
+		case int:
+			dst = AppendInt64(dst, int64(v))
+		case int64:
+			dst = AppendInt64(dst, v)
+		case int32:
+			dst = AppendInt32(dst, v)
+		case uint:
+			dst = AppendUint64(dst, uint64(v))
+		case uint64:
+			dst = AppendUint64(dst, v)
+		case uint32:
+			dst = AppendUint32(dst, v)
+		case float64:
+			dst = AppendFloat64(dst, v)
+		case float32:
+			dst = AppendFloat32(dst, v)
+		case bool:
+			dst = AppendBool(dst, v)
+		case string:
+			dst = AppendString(dst, v)
+		case []byte:
+			dst = AppendBytes(dst, v)
+		case time.Time:
+			dst = AppendTime(dst, v)
+		default:
+			return nil, fmt.Errorf("keys: unsupported type %T", value)
+		}
+	}
+	return dst, nil
+}
+
+// Scan decodes a tuple into dst, which are pointers to the types of the values of
+// the tuple, in the same order. It returns the bytes left after the tuple.
+func Scan(b []byte, dst ...any) (rest []byte, err error) {
+	rest = b
+	for _, d := range dst {
+		switch v := d.(type) {
+		case *int:
+			var i int64
+			i, rest, err = DecodeInt64(rest)
+			*v = int(i)
+		case *int64:
+			*v, rest, err = DecodeInt64(rest)
+		case *int32:
+			*v, rest, err = DecodeInt32(rest)
+		case *uint:
+			var u uint64
+			u, rest, err = DecodeUint64(rest)
+			*v = uint(u)
+		case *uint64:
+			*v, rest, err = DecodeUint64(rest)
+		case *uint32:
+			*v, rest, err = DecodeUint32(rest)
+		case *float64:
+			*v, rest, err = DecodeFloat64(rest)
+		case *float32:
+			*v, rest, err = DecodeFloat32(rest)
+		case *bool:
+			*v, rest, err = DecodeBool(rest)
+		case *string:
+			*v, rest, err = DecodeString(rest)
+		case *[]byte:
+			*v, rest, err = DecodeBytes(rest)
+		case *time.Time:
+			*v, rest, err = DecodeTime(rest)
+		default:
+			return b, fmt.Errorf("keys: unsupported type %T", d)
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+	return rest, nil
+}
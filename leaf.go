@@ -5,9 +5,11 @@ import (
 	"fmt"
 )
 
+// The value goes before the key, a zero-size value at the end of the struct
+// would be padded, the leaf of a Set would be larger than its key.
 type leaf[T any] struct {
-	key   Key
 	value T
+	key   Key
 }
 
 func (l leaf[T]) Kind() Kind {
@@ -30,8 +32,10 @@ func (l *leaf[T]) insert(other *leaf[T], depth int, parent *olock, parentVersion
 	}
 	nn.setPrefix(other.key[depth:], longestPrefix)
 
-	nn.node.addChild(l.key.At(depth+longestPrefix), l)
-	nn.node.addChild(other.key.At(depth+longestPrefix), other)
+	// one of the keys might be the prefix of the other one,
+	// it will be stored as the leaf of the new node.
+	nn.addLeaf(l, depth+longestPrefix)
+	nn.addLeaf(other, depth+longestPrefix)
 
 	return nn, false, false
 }
@@ -48,7 +52,7 @@ func (l leaf[T]) get(key Key, i int, o *olock, u uint64) (value T, found bool, r
 }
 
 func (l *leaf[T]) walk(fn walkFn[T], depth int) bool {
-	panic("implement me")
+	return fn(l, depth)
 }
 
 func (n *leaf[T]) addPrefixBefore(node *inner[T], key byte) {
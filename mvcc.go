@@ -0,0 +1,187 @@
+package art
+
+import (
+	"sync/atomic"
+)
+
+// version is a value of a key in an MVCCTree. The versions of a key are chained from
+// the newest to the oldest, a version is never modified once it's in the chain, the
+// versions before it are copied instead, so that the readers follow the chain without locks.
+type version[T any] struct {
+	ts    uint64
+	value T
+	// deleted is the tombstone of a removal
+	deleted bool
+	next    *version[T]
+}
+
+// at returns the newest version at or before ts, if any.
+func (v *version[T]) at(ts uint64) *version[T] {
+	for ; v != nil; v = v.next {
+		if v.ts <= ts {
+			return v
+		}
+	}
+	return nil
+}
+
+// MVCCTree is a Tree keeping the versions of the values, by timestamp. The nodes
+// are shared by all the versions, every leaf holds the chain of the versions of its key.
+//
+// The versions older than the watermark are garbage collected by GC: the reads at a
+// timestamp below the watermark may miss them. The zero value is an empty tree.
+type MVCCTree[T any] struct {
+	tree      Tree[*version[T]]
+	watermark uint64
+}
+
+// InsertAt stores value for key at ts, replacing the version at ts if there is one.
+func (t *MVCCTree[T]) InsertAt(key Key, value T, ts uint64) {
+	t.write(key, &version[T]{ts: ts, value: value})
+}
+
+// RemoveAt removes key at ts, the reads at ts and later don't see the key, until a newer version.
+// A key without versions is left as it is, a version inserted afterwards before ts isn't hidden by the removal.
+func (t *MVCCTree[T]) RemoveAt(key Key, ts uint64) {
+	t.write(key, &version[T]{ts: ts, deleted: true})
+}
+
+func (t *MVCCTree[T]) write(key Key, v *version[T]) {
+	watermark := atomic.LoadUint64(&t.watermark)
+	t.tree.Compute(key, func(head *version[T], _ bool) (*version[T], Action) {
+		if head == nil && v.deleted {
+			// no version to hide, the tombstone would only take space until GC
+			return head, Keep
+		}
+		if pruned := prune(insertVersion(head, v), watermark); pruned != nil {
+			return pruned, Store
+		}
+		return head, Delete
+	})
+}
+
+// insertVersion returns the chain of head with v inserted in timestamp order.
+func insertVersion[T any](head, v *version[T]) *version[T] {
+	if head == nil || head.ts < v.ts {
+		v.next = head
+		return v
+	}
+	if head.ts == v.ts {
+		v.next = head.next
+		return v
+	}
+	nv := *head
+	nv.next = insertVersion(head.next, v)
+	return &nv
+}
+
+// prune returns the chain of head without the versions older than the newest one at or
+// before the watermark, which the reads at the watermark and later never see. A tombstone
+// at the end of the chain is dropped as well. It returns nil if no version is left.
+func prune[T any](head *version[T], watermark uint64) *version[T] {
+	visible := head.at(watermark)
+	if visible == nil || (visible.next == nil && !visible.deleted) {
+		return head
+	}
+	// copy the versions newer than the visible one, then the visible one as the last
+	var pruned *version[T]
+	link := &pruned
+	for v := head; v != visible; v = v.next {
+		nv := *v
+		*link = &nv
+		link = &nv.next
+	}
+	if visible.deleted {
+		*link = nil
+	} else {
+		nv := *visible
+		nv.next = nil
+		*link = &nv
+	}
+	return pruned
+}
+
+// SearchAt returns the value of key at ts, the newest one at or before ts.
+func (t *MVCCTree[T]) SearchAt(key Key, ts uint64) (value T, found bool) {
+	head, found := t.tree.Search(key)
+	if !found {
+		return value, false
+	}
+	if v := head.at(ts); v != nil && !v.deleted {
+		return v.value, true
+	}
+	return value, false
+}
+
+// IteratorAt returns an iterator in range (start, end] over the keys and the values at ts, see Tree.Iterator.
+func (t *MVCCTree[T]) IteratorAt(start, end []byte, ts uint64) *mvccIterator[T] {
+	return &mvccIterator[T]{iterator: t.tree.Iterator(start, end), ts: ts}
+}
+
+// GC raises the watermark to watermark and removes the versions older than it, like the keys
+// removed before it. It returns the number of keys removed. The watermark never goes down.
+func (t *MVCCTree[T]) GC(watermark uint64) (removed int) {
+	for {
+		current := atomic.LoadUint64(&t.watermark)
+		if watermark <= current {
+			watermark = current
+			break
+		}
+		if atomic.CompareAndSwapUint64(&t.watermark, current, watermark) {
+			break
+		}
+	}
+	for iter := t.tree.Iterator(nil, nil); iter.Next(); {
+		t.tree.Compute(iter.Key(), func(head *version[T], exists bool) (*version[T], Action) {
+			if !exists {
+				return head, Keep
+			}
+			pruned := prune(head, watermark)
+			switch {
+			case pruned == nil:
+				removed++
+				return head, Delete
+			case pruned == head:
+				return head, Keep
+			}
+			return pruned, Store
+		})
+	}
+	return removed
+}
+
+type mvccIterator[T any] struct {
+	iterator *iterator[*version[T]]
+	ts       uint64
+	current  *version[T]
+}
+
+// Reverse turns the iteration into the reverse order, see Tree.Iterator.
+func (i *mvccIterator[T]) Reverse() *mvccIterator[T] {
+	i.iterator.Reverse()
+	return i
+}
+
+// Next moves to the next key of the range present at the timestamp of the iterator.
+func (i *mvccIterator[T]) Next() bool {
+	i.current = nil
+	for i.iterator.Next() {
+		if v := i.iterator.Value().at(i.ts); v != nil && !v.deleted {
+			i.current = v
+			return true
+		}
+	}
+	return false
+}
+
+func (i *mvccIterator[T]) Key() Key {
+	return i.iterator.Key()
+}
+
+// Value returns the value of the current key, the zero value before Next or after it returned false.
+func (i *mvccIterator[T]) Value() (value T) {
+	if i.current == nil {
+		return value
+	}
+	return i.current.value
+}
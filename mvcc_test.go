@@ -0,0 +1,180 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMVCCTree(t *testing.T) {
+	tree := MVCCTree[string]{}
+	tree.InsertAt(Key("a"), "a10", 10)
+	tree.InsertAt(Key("a"), "a30", 30)
+	// out of order
+	tree.InsertAt(Key("a"), "a20", 20)
+	tree.RemoveAt(Key("a"), 40)
+	tree.InsertAt(Key("b"), "b15", 15)
+
+	for _, tc := range []struct {
+		ts       uint64
+		expected string
+		found    bool
+	}{
+		{ts: 9},
+		{ts: 10, expected: "a10", found: true},
+		{ts: 25, expected: "a20", found: true},
+		{ts: 39, expected: "a30", found: true},
+		{ts: 40},
+	} {
+		value, found := tree.SearchAt(Key("a"), tc.ts)
+		assert.Equalf(t, tc.found, found, "at %d", tc.ts)
+		assert.Equalf(t, tc.expected, value, "at %d", tc.ts)
+	}
+
+	at := func(ts uint64) (values []string) {
+		for iter := tree.IteratorAt(nil, nil, ts); iter.Next(); {
+			values = append(values, string(iter.Key())+"="+iter.Value())
+		}
+		return values
+	}
+	assert.Equal(t, []string{"a=a10"}, at(10))
+	assert.Equal(t, []string{"a=a20", "b=b15"}, at(20))
+	assert.Equal(t, []string{"b=b15"}, at(40))
+	var reversed []string
+	for iter := tree.IteratorAt(nil, nil, 20).Reverse(); iter.Next(); {
+		reversed = append(reversed, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"b", "a"}, reversed)
+
+	// the value is zero before and after the iteration
+	iter := tree.IteratorAt(nil, nil, 10)
+	assert.Equal(t, "", iter.Value())
+	for iter.Next() {
+	}
+	assert.Equal(t, "", iter.Value())
+
+	// replaced at the same timestamp
+	tree.InsertAt(Key("b"), "b15'", 15)
+	value, _ := tree.SearchAt(Key("b"), 15)
+	assert.Equal(t, "b15'", value)
+
+	assert.Equal(t, 0, tree.GC(25))
+	value, found := tree.SearchAt(Key("a"), 25)
+	assert.True(t, found)
+	assert.Equal(t, "a20", value)
+	// the version older than the watermark is gone
+	_, found = tree.SearchAt(Key("a"), 10)
+	assert.False(t, found)
+
+	// the key removed before the watermark is gone
+	assert.Equal(t, 1, tree.GC(40))
+	assert.Equal(t, 1, tree.tree.Len())
+	// the watermark never goes down
+	assert.Equal(t, 0, tree.GC(0))
+	tree.InsertAt(Key("c"), "c1", 1)
+	tree.RemoveAt(Key("c"), 2)
+	assert.Equal(t, 1, tree.tree.Len())
+	// removing a missing key stores nothing
+	tree.RemoveAt(Key("missing"), 50)
+	assert.Equal(t, 1, tree.tree.Len())
+}
+
+type mvccVersion struct {
+	ts      uint64
+	value   int
+	deleted bool
+}
+
+func TestMVCCTreeProperty(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		tree := MVCCTree[int]{}
+		ref := map[string][]mvccVersion{}
+		// at returns the value of the reference at ts
+		at := func(key string, ts uint64) (value int, found bool) {
+			var newest *mvccVersion
+			for i, v := range ref[key] {
+				if v.ts <= ts && (newest == nil || v.ts >= newest.ts) {
+					newest = &ref[key][i]
+				}
+			}
+			if newest == nil || newest.deleted {
+				return 0, false
+			}
+			return newest.value, true
+		}
+		watermark := uint64(0)
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprint(rng.Intn(20))
+			ts := watermark + uint64(rng.Intn(100))
+			v := mvccVersion{ts: ts, value: i, deleted: rng.Intn(4) == 0}
+			// the version at the same timestamp is replaced, a key without versions isn't removed
+			if _, ok := tree.tree.Search(Key(key)); ok || !v.deleted {
+				versions := ref[key][:0:0]
+				for _, old := range ref[key] {
+					if old.ts != ts {
+						versions = append(versions, old)
+					}
+				}
+				ref[key] = append(versions, v)
+			}
+			if v.deleted {
+				tree.RemoveAt(Key(key), ts)
+			} else {
+				tree.InsertAt(Key(key), i, ts)
+			}
+			if rng.Intn(100) == 0 {
+				watermark += uint64(rng.Intn(50))
+				tree.GC(watermark)
+			}
+
+			readTs := watermark + uint64(rng.Intn(120))
+			for k := 0; k < 20; k++ {
+				expected, exists := at(fmt.Sprint(k), readTs)
+				value, found := tree.SearchAt(Key(fmt.Sprint(k)), readTs)
+				require.Equalf(t, exists, found, "seed %d, key %d at %d", seed, k, readTs)
+				require.Equal(t, expected, value)
+			}
+		}
+	}
+}
+
+func TestMVCCTree_ConcurrentReadAt(t *testing.T) {
+	t.Parallel()
+	const (
+		keys     = 100
+		versions = 100
+	)
+	tree := MVCCTree[int]{}
+	for k := 0; k < keys; k++ {
+		tree.InsertAt(Key(fmt.Sprintf("key::%03d", k)), 0, 1)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ts := uint64(2); ts < versions; ts++ {
+			for k := 0; k < keys; k++ {
+				tree.InsertAt(Key(fmt.Sprintf("key::%03d", k)), int(ts), ts)
+			}
+			tree.GC(ts / 2)
+		}
+	}()
+	// every key is seen while its versions are added and collected
+	for i := 0; i < 100; i++ {
+		count := 0
+		for iter := tree.IteratorAt(nil, nil, versions); iter.Next(); count++ {
+		}
+		assert.Equal(t, keys, count)
+		value, found := tree.SearchAt(Key("key::000"), versions)
+		assert.True(t, found)
+		assert.GreaterOrEqual(t, value, 0)
+	}
+	wg.Wait()
+	value, _ := tree.SearchAt(Key("key::050"), versions)
+	assert.Equal(t, versions-1, value)
+}
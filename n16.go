@@ -46,25 +46,18 @@ func (n *node16[T]) child(k byte) (int, node[T]) {
 }
 
 func (n *node16[T]) next(k *byte) (byte, node[T]) {
-	if k == nil {
-		return n.keys[0], n.children[0]
-	}
-	for i, b := range n.keys {
-		if b > *k {
-			return b, n.children[i]
+	for idx := uint8(0); idx < n.lth; idx++ {
+		if k == nil || n.keys[idx] > *k {
+			return n.keys[idx], n.children[idx]
 		}
 	}
 	return 0, nil
 }
 
 func (n *node16[T]) prev(k *byte) (byte, node[T]) {
-	if k == nil {
-		idx := n.lth - 1
-		return n.keys[idx], n.children[idx]
-	}
-	for i := n.lth; i >= 0; i-- {
+	for i := n.lth; i > 0; i-- {
 		idx := i - 1
-		if n.keys[idx] < *k {
+		if k == nil || n.keys[idx] < *k {
 			return n.keys[idx], n.children[idx]
 		}
 	}
@@ -112,6 +105,15 @@ func (n *node16[T]) grow() inode[T] {
 	return nn
 }
 
+func (n *node16[T]) size() int {
+	return int(n.lth)
+}
+
+func (n *node16[T]) clone() inode[T] {
+	nn := *n
+	return &nn
+}
+
 func (n *node16[T]) min() bool {
 	return n.lth <= 5
 }
@@ -26,8 +26,12 @@ func (n *node256[T]) child(k byte) (int, node[T]) {
 }
 
 func (n *node256[T]) next(k *byte) (byte, node[T]) {
-	for b, child := range n.children {
-		if (k == nil || byte(b) > *k) && child != nil {
+	b := 0
+	if k != nil {
+		b = int(*k) + 1
+	}
+	for ; b < len(n.children); b++ {
+		if child := n.children[b]; child != nil {
 			return byte(b), child
 		}
 	}
@@ -35,11 +39,13 @@ func (n *node256[T]) next(k *byte) (byte, node[T]) {
 }
 
 func (n *node256[T]) prev(k *byte) (byte, node[T]) {
-	for idx := n.lth - 1; idx >= 0; idx-- {
-		b := byte(idx)
-		child := n.children[idx]
-		if (k == nil || b < *k) && child != nil {
-			return b, child
+	b := len(n.children) - 1
+	if k != nil {
+		b = int(*k) - 1
+	}
+	for ; b >= 0; b-- {
+		if child := n.children[b]; child != nil {
+			return byte(b), child
 		}
 	}
 	return 0, nil
@@ -67,6 +73,15 @@ func (n *node256[T]) grow() inode[T] {
 	return nil
 }
 
+func (n *node256[T]) size() int {
+	return int(n.lth)
+}
+
+func (n *node256[T]) clone() inode[T] {
+	nn := *n
+	return &nn
+}
+
 func (n *node256[T]) min() bool {
 	return n.lth <= 49
 }
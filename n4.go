@@ -22,28 +22,19 @@ func (n *node4[T]) index(k byte) int {
 }
 
 func (n *node4[T]) next(k *byte) (byte, node[T]) {
-	if k == nil {
-		return n.keys[0], n.children[0]
-	}
-	for idx, b := range n.keys {
-		if b > *k {
-			return b, n.children[idx]
+	// the keys past lth are unused
+	for idx := uint8(0); idx < n.lth; idx++ {
+		if k == nil || n.keys[idx] > *k {
+			return n.keys[idx], n.children[idx]
 		}
 	}
 	return 0, nil
 }
 
 func (n *node4[T]) prev(k *byte) (byte, node[T]) {
-	if n.lth == 0 {
-		return 0, nil
-	}
-	if k == nil {
-		idx := n.lth - 1
-		return n.keys[idx], n.children[idx]
-	}
 	for i := n.lth; i > 0; i-- {
 		idx := i - 1
-		if n.keys[idx] < *k {
+		if k == nil || n.keys[idx] < *k {
 			return n.keys[idx], n.children[idx]
 		}
 	}
@@ -103,6 +94,15 @@ func (n *node4[T]) grow() inode[T] {
 	return nn
 }
 
+func (n *node4[T]) size() int {
+	return int(n.lth)
+}
+
+func (n *node4[T]) clone() inode[T] {
+	nn := *n
+	return &nn
+}
+
 func (n *node4[T]) min() bool {
 	return n.lth <= 2
 }
@@ -32,8 +32,12 @@ func (n *node48[T]) child(k byte) (int, node[T]) {
 }
 
 func (n *node48[T]) next(k *byte) (byte, node[T]) {
-	for b, idx := range n.keys {
-		if (k == nil || byte(b) > *k) && idx != 0 {
+	b := 0
+	if k != nil {
+		b = int(*k) + 1
+	}
+	for ; b < len(n.keys); b++ {
+		if idx := n.keys[b]; idx != 0 {
 			return byte(b), n.children[idx-1]
 		}
 	}
@@ -41,10 +45,14 @@ func (n *node48[T]) next(k *byte) (byte, node[T]) {
 }
 
 func (n *node48[T]) prev(k *byte) (byte, node[T]) {
-	for b := n.lth - 1; b >= 0; b-- {
-		idx := n.keys[b]
-		if (k == nil || byte(b) < *k) && idx != 0 {
-			return byte(b), n.children[idx]
+	// the keys are indexed by byte, the scan starts from the last possible one
+	b := len(n.keys) - 1
+	if k != nil {
+		b = int(*k) - 1
+	}
+	for ; b >= 0; b-- {
+		if idx := n.keys[b]; idx != 0 {
+			return byte(b), n.children[idx-1]
 		}
 	}
 	return 0, nil
@@ -93,6 +101,15 @@ func (n *node48[T]) replace(k int, child node[T]) (old node[T]) {
 	return
 }
 
+func (n *node48[T]) size() int {
+	return int(n.lth)
+}
+
+func (n *node48[T]) clone() inode[T] {
+	nn := *n
+	return &nn
+}
+
 func (n *node48[T]) min() bool {
 	return n.lth <= 17
 }
@@ -117,9 +134,10 @@ func (n *node48[T]) shrink() inode[T] {
 }
 
 func (n *node48[T]) walk(fn walkFn[T], depth int) bool {
-	for _, child := range n.children {
-		if child != nil {
-			if !child.walk(fn, depth) {
+	// the children are visited in the order of the keys, not of the slots
+	for _, idx := range n.keys {
+		if idx != 0 {
+			if !n.children[idx-1].walk(fn, depth) {
 				return false
 			}
 		}
@@ -15,10 +15,12 @@ const (
 	Node256
 )
 
-// At return a char at post
+// At return a char at post, or 0 if pos is out of the key.
+//
+// Note that 0 is a valid char of a key as well, the tree never relies on At
+// to tell the end of a key apart from it.
 func (key Key) At(pos int) byte {
 	if pos < 0 || pos >= len(key) {
-		// imitate the C-like string termination character
 		return 0
 	}
 	return key[pos]
@@ -40,7 +42,29 @@ type inner[T any] struct {
 	lock      olock
 	prefix    [maxPrefixLen]byte
 	prefixLen int
-	node      inode[T]
+	// leaf is the key terminating right after the prefix of the node, which is
+	// the prefix of all the keys stored in the children.
+	leaf *leaf[T]
+	node inode[T]
+	// epoch is the generation of the tree the node was created in. Nodes of a
+	// frozen generation are shared with snapshots and never modified in place.
+	epoch *generation
+}
+
+// NodeInfo describes a node visited by Walk.
+type NodeInfo struct {
+	Kind Kind
+	// Depth is the number of inner nodes above the node
+	Depth int
+	// Prefix is the prefix stored in an inner node, it is cut at maxPrefixLen
+	// bytes while PrefixLen is the full length of the prefix.
+	Prefix    []byte
+	PrefixLen int
+	// Children is the number of children of an inner node, the leaf of
+	// the node isn't counted, it is visited right before the children.
+	Children int
+	// Key is the key of a leaf
+	Key Key
 }
 
 // walkFn should return false if iteration should be terminated.
@@ -69,11 +93,16 @@ type inode[T any] interface {
 	// node256 can't grow and will return nil
 	grow() inode[T]
 
+	// size returns the number of children
+	size() int
+
 	// min is true if node reached min size
 	min() bool
 	// shrink is the opposite to grow
 	// if node is of the smallest type (node4) nil will be returned
 	shrink() inode[T]
+	// clone returns a copy of the node sharing its children
+	clone() inode[T]
 
 	// walk is internal helper to iterate in depth first order over all nodes, including inner nodes
 	walk(walkFn[T], int) bool
@@ -0,0 +1,178 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkOrder verifies next and prev of n from every byte against the sorted keys of the children.
+func checkOrder(t *testing.T, n inode[Value], keys []byte) {
+	t.Helper()
+	require.Equal(t, len(keys), n.size())
+
+	var forward, reverse []byte
+	for b, child := n.next(nil); child != nil; b, child = n.next(&b) {
+		require.Equal(t, Key{b}, child.(*leaf[Value]).key)
+		forward = append(forward, b)
+	}
+	for b, child := n.prev(nil); child != nil; b, child = n.prev(&b) {
+		require.Equal(t, Key{b}, child.(*leaf[Value]).key)
+		reverse = append(reverse, b)
+	}
+	for i, j := 0, len(reverse)-1; i < j; i, j = i+1, j-1 {
+		reverse[i], reverse[j] = reverse[j], reverse[i]
+	}
+	if len(keys) == 0 {
+		keys = nil
+	}
+	require.Equal(t, keys, forward, n.String())
+	require.Equal(t, keys, reverse, n.String())
+
+	for k := 0; k < 256; k++ {
+		b := byte(k)
+		i := sort.Search(len(keys), func(i int) bool { return keys[i] > b })
+		next, child := n.next(&b)
+		if i < len(keys) {
+			require.Equal(t, keys[i], next, "next of %d in %s", b, n)
+			require.NotNil(t, child)
+		} else {
+			require.Nil(t, child, "next of %d in %s", b, n)
+		}
+
+		i = sort.Search(len(keys), func(i int) bool { return keys[i] >= b }) - 1
+		prev, child := n.prev(&b)
+		if i >= 0 {
+			require.Equal(t, keys[i], prev, "prev of %d in %s", b, n)
+			require.NotNil(t, child)
+		} else {
+			require.Nil(t, child, "prev of %d in %s", b, n)
+		}
+	}
+}
+
+func TestNodeOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		size int
+		kind Kind
+	}{
+		{name: "empty", size: 0, kind: Node4},
+		{name: "node4 single", size: 1, kind: Node4},
+		{name: "node4 full", size: 4, kind: Node4},
+		{name: "node16", size: 5, kind: Node16},
+		{name: "node16 full", size: 16, kind: Node16},
+		{name: "node48", size: 17, kind: Node48},
+		{name: "node48 full", size: 48, kind: Node48},
+		{name: "node256", size: 49, kind: Node256},
+		{name: "node256 full", size: 256, kind: Node256},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(int64(tc.size)))
+			// the edge bytes are always used, they are the easiest to get wrong
+			keys := append([]byte{0, 255}, byte(rng.Intn(256)))
+			for _, b := range rng.Perm(256) {
+				keys = append(keys, byte(b))
+			}
+			var n inode[Value] = &node4[Value]{}
+			added := map[byte]bool{}
+			for _, b := range keys {
+				if len(added) == tc.size {
+					break
+				}
+				if added[b] {
+					continue
+				}
+				if n.full() {
+					n = n.grow()
+				}
+				n.addChild(b, &leaf[Value]{key: Key{b}})
+				added[b] = true
+			}
+			sorted := make([]byte, 0, len(added))
+			for b := range added {
+				sorted = append(sorted, b)
+			}
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			assert.Equal(t, tc.kind, n.Kind())
+			checkOrder(t, n, sorted)
+
+			// remove the children in random order, shrinking the node the way the tree does
+			for _, i := range rng.Perm(len(sorted)) {
+				b := sorted[i]
+				_, isNode4 := n.(*node4[Value])
+				min := n.min()
+				idx, child := n.child(b)
+				require.NotNil(t, child, fmt.Sprintf("child %d of %s", b, n))
+				n.replace(idx, nil)
+				if min && !isNode4 {
+					n = n.shrink()
+				}
+				delete(added, b)
+				left := sorted[:0:0]
+				for _, k := range sorted {
+					if added[k] {
+						left = append(left, k)
+					}
+				}
+				checkOrder(t, n, left)
+			}
+		})
+	}
+}
+
+func TestTree_ReverseAcrossNodeKinds(t *testing.T) {
+	for _, size := range []int{3, 4, 12, 16, 40, 48, 200, 256} {
+		t.Run(fmt.Sprintf("%d", size), func(t *testing.T) {
+			tree := Tree[int]{}
+			var keys []string
+			for i := 0; i < size; i++ {
+				key := string([]byte{'k', byte(i)})
+				tree.Insert(Key(key), i)
+				keys = append(keys, key)
+			}
+			// the parent of the keys is a leaf as well
+			tree.Insert(Key("k"), -1)
+
+			var reverse []string
+			for iter := tree.Iterator(nil, nil).Reverse(); iter.Next(); {
+				reverse = append(reverse, string(iter.Key()))
+			}
+			require.Len(t, reverse, size+1)
+			for i, key := range reverse[:size] {
+				assert.Equal(t, keys[size-1-i], key)
+			}
+			assert.Equal(t, "k", reverse[size])
+
+			// the latest N keys
+			c := tree.Cursor()
+			var latest []int
+			for ok := c.Last(); ok && len(latest) < 3; ok = c.Prev() {
+				latest = append(latest, c.Value())
+			}
+			assert.Equal(t, []int{size - 1, size - 2, size - 3}, latest)
+
+			// shrink the node back by removing every other key
+			for i := 0; i < size; i += 2 {
+				tree.Remove(Key(keys[i]))
+			}
+			var forward []string
+			for ok := c.First(); ok; ok = c.Next() {
+				forward = append(forward, string(c.Key()))
+			}
+			var backward []string
+			for ok := c.Last(); ok; ok = c.Prev() {
+				backward = append([]string{string(c.Key())}, backward...)
+			}
+			assert.Equal(t, forward, backward)
+			require.Len(t, forward, size/2+1)
+			for i, key := range forward[1:] {
+				assert.Equal(t, keys[2*i+1], key)
+			}
+		})
+	}
+}
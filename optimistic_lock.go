@@ -31,6 +31,12 @@ func (ol *olock) RLock() (uint64, bool) {
 	return version, isObsolete(version)
 }
 
+// TryRLock is RLock without waiting, ok is false if the node is write locked.
+func (ol *olock) TryRLock() (version uint64, obsolete, ok bool) {
+	version = atomic.LoadUint64(&ol.version)
+	return version, isObsolete(version), version&2 != 2
+}
+
 // RUnlock compares read lock with current value of the olock, in case if
 // value got changed - RUnlock will return true.
 func (ol *olock) RUnlock(version uint64, locked *olock) bool {
@@ -83,6 +89,11 @@ func (ol *olock) UnlockObsolete() {
 	atomic.AddUint64(&ol.version, 3)
 }
 
+// wait returns once the lock isn't held, it doesn't lock it.
+func (ol *olock) wait() {
+	ol.waitUnlocked()
+}
+
 func (ol *olock) waitUnlocked() uint64 {
 	for {
 		version := atomic.LoadUint64(&ol.version)
@@ -19,6 +19,10 @@ func (ol *olock) RLock() (uint64, bool) {
 	return 0, false
 }
 
+func (ol *olock) TryRLock() (uint64, bool, bool) {
+	return 0, false, ol.mu.TryLock()
+}
+
 // RUnlock compares read lock with current value of the olock, in case if
 // value got changed - RUnlock will return true.
 func (ol *olock) RUnlock(version uint64, locked *olock) bool {
@@ -47,3 +51,8 @@ func (ol *olock) Unlock() {
 func (ol *olock) UnlockObsolete() {
 	ol.mu.Unlock()
 }
+
+func (ol *olock) wait() {
+	ol.mu.Lock()
+	ol.mu.Unlock()
+}
@@ -0,0 +1,150 @@
+package art
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/WenyXu/sync-adaptive-radix-tree/keys"
+)
+
+// KeyCodec converts the keys of an OrderedMap to the keys of the tree. The order of the
+// encoded keys is the order of the map, Decode is called only with the keys of Encode.
+type KeyCodec[K any] interface {
+	Encode(K) Key
+	Decode(Key) K
+}
+
+// StringCodec encodes a string as its bytes.
+type StringCodec struct{}
+
+func (StringCodec) Encode(k string) Key {
+	return Key(k)
+}
+
+func (StringCodec) Decode(key Key) string {
+	return string(key)
+}
+
+// Uint64Codec encodes an uint64 with keys.AppendUint64.
+type Uint64Codec struct{}
+
+func (Uint64Codec) Encode(k uint64) Key {
+	return keys.AppendUint64(nil, k)
+}
+
+func (Uint64Codec) Decode(key Key) uint64 {
+	return decode(keys.DecodeUint64(key))
+}
+
+// Int64Codec encodes an int64 with keys.AppendInt64, the negative keys come first.
+type Int64Codec struct{}
+
+func (Int64Codec) Encode(k int64) Key {
+	return keys.AppendInt64(nil, k)
+}
+
+func (Int64Codec) Decode(key Key) int64 {
+	return decode(keys.DecodeInt64(key))
+}
+
+// Float64Codec encodes a float64 with keys.AppendFloat64.
+type Float64Codec struct{}
+
+func (Float64Codec) Encode(k float64) Key {
+	return keys.AppendFloat64(nil, k)
+}
+
+func (Float64Codec) Decode(key Key) float64 {
+	return decode(keys.DecodeFloat64(key))
+}
+
+// TimeCodec encodes a time.Time with keys.AppendTime, the decoded keys are in UTC.
+type TimeCodec struct{}
+
+func (TimeCodec) Encode(k time.Time) Key {
+	return keys.AppendTime(nil, k)
+}
+
+func (TimeCodec) Decode(key Key) time.Time {
+	return decode(keys.DecodeTime(key))
+}
+
+// TupleCodec encodes a tuple of values with keys.Tuple. Types holds a value of the type
+// of each element of the tuples, in order, Decode returns elements of these types.
+// Encode panics if an element has a type keys.Tuple doesn't support.
+type TupleCodec struct {
+	Types []any
+}
+
+func (c TupleCodec) Encode(k []any) Key {
+	key, err := keys.Tuple(k...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func (c TupleCodec) Decode(key Key) []any {
+	dst := make([]any, len(c.Types))
+	for i, typ := range c.Types {
+		dst[i] = reflect.New(reflect.TypeOf(typ)).Interface()
+	}
+	if _, err := keys.Scan(key, dst...); err != nil {
+		panic(err)
+	}
+	for i, d := range dst {
+		dst[i] = reflect.ValueOf(d).Elem().Interface()
+	}
+	return dst
+}
+
+// decode returns the value of a keys decoder, the keys of the tree are encoded by the
+// codecs so an error is a bug.
+func decode[K any](k K, _ []byte, err error) K {
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// OrderedMap is a Tree with keys of type K, which are converted by a KeyCodec.
+type OrderedMap[K, V any] struct {
+	tree  Tree[V]
+	codec KeyCodec[K]
+}
+
+// NewOrderedMap returns an empty map of the keys encoded by codec.
+func NewOrderedMap[K, V any](codec KeyCodec[K]) *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{codec: codec}
+}
+
+// Get returns the value of key.
+func (m *OrderedMap[K, V]) Get(key K) (value V, found bool) {
+	return m.tree.Search(m.codec.Encode(key))
+}
+
+// Set stores value for key, updated is true if the key was present.
+func (m *OrderedMap[K, V]) Set(key K, value V) (updated bool) {
+	return m.tree.Insert(m.codec.Encode(key), value)
+}
+
+// Delete removes key and returns its value, deleted is true if the key was present.
+func (m *OrderedMap[K, V]) Delete(key K) (value V, deleted bool) {
+	deleted, value = m.tree.Remove(m.codec.Encode(key))
+	return value, deleted
+}
+
+// Range calls fn for every key of the map in order, until fn returns false.
+// Like Tree.Iterator it doesn't correspond to a consistent snapshot of the map.
+func (m *OrderedMap[K, V]) Range(fn func(key K, value V) bool) {
+	for iter := m.tree.Iterator(nil, nil); iter.Next(); {
+		if !fn(m.codec.Decode(iter.Key()), iter.Value()) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap[K, V]) Len() int {
+	return m.tree.Len()
+}
@@ -0,0 +1,114 @@
+package art
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap[string, int](StringCodec{})
+	assert.False(t, m.Set("b", 1))
+	assert.False(t, m.Set("a", 2))
+	assert.True(t, m.Set("b", 3))
+	value, found := m.Get("b")
+	assert.True(t, found)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, 2, m.Len())
+
+	var keys []string
+	m.Range(func(key string, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	value, deleted := m.Delete("a")
+	assert.True(t, deleted)
+	assert.Equal(t, 2, value)
+	_, deleted = m.Delete("a")
+	assert.False(t, deleted)
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestOrderedMap_IntegerOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	signed := NewOrderedMap[int64, int](Int64Codec{})
+	unsigned := NewOrderedMap[uint64, int](Uint64Codec{})
+	var expectedSigned []int64
+	var expectedUnsigned []uint64
+	for _, k := range []int64{math.MinInt64, -1, 0, 1, math.MaxInt64} {
+		signed.Set(k, 0)
+		expectedSigned = append(expectedSigned, k)
+	}
+	for i := 0; i < 1000; i++ {
+		k := rng.Int63() - rng.Int63()
+		if !signed.Set(k, i) {
+			expectedSigned = append(expectedSigned, k)
+		}
+		u := rng.Uint64()
+		if !unsigned.Set(u, i) {
+			expectedUnsigned = append(expectedUnsigned, u)
+		}
+	}
+	sort.Slice(expectedSigned, func(i, j int) bool { return expectedSigned[i] < expectedSigned[j] })
+	sort.Slice(expectedUnsigned, func(i, j int) bool { return expectedUnsigned[i] < expectedUnsigned[j] })
+
+	var gotSigned []int64
+	signed.Range(func(key int64, _ int) bool {
+		gotSigned = append(gotSigned, key)
+		return true
+	})
+	var gotUnsigned []uint64
+	unsigned.Range(func(key uint64, _ int) bool {
+		gotUnsigned = append(gotUnsigned, key)
+		return true
+	})
+	require.Equal(t, expectedSigned, gotSigned)
+	require.Equal(t, expectedUnsigned, gotUnsigned)
+}
+
+func TestOrderedMap_Codecs(t *testing.T) {
+	floats := NewOrderedMap[float64, int](Float64Codec{})
+	for i, k := range []float64{1.5, math.Inf(-1), -2, 0, math.Inf(1), -0.5} {
+		floats.Set(k, i)
+	}
+	var gotFloats []float64
+	floats.Range(func(key float64, _ int) bool {
+		gotFloats = append(gotFloats, key)
+		return true
+	})
+	assert.Equal(t, []float64{math.Inf(-1), -2, -0.5, 0, 1.5, math.Inf(1)}, gotFloats)
+
+	times := NewOrderedMap[time.Time, int](TimeCodec{})
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, d := range []time.Duration{time.Hour, -time.Second, time.Nanosecond, 0} {
+		times.Set(base.Add(d).In(time.FixedZone("east", 3600)), i)
+	}
+	var gotTimes []time.Time
+	times.Range(func(key time.Time, _ int) bool {
+		gotTimes = append(gotTimes, key)
+		return true
+	})
+	assert.Equal(t, []time.Time{base.Add(-time.Second), base, base.Add(time.Nanosecond), base.Add(time.Hour)}, gotTimes)
+
+	tuples := NewOrderedMap[[]any, int](TupleCodec{Types: []any{"", int64(0)}})
+	tuples.Set([]any{"b", int64(1)}, 0)
+	tuples.Set([]any{"a", int64(2)}, 1)
+	tuples.Set([]any{"a", int64(-1)}, 2)
+	value, found := tuples.Get([]any{"a", int64(2)})
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+	var gotTuples [][]any
+	tuples.Range(func(key []any, _ int) bool {
+		gotTuples = append(gotTuples, key)
+		return true
+	})
+	assert.Equal(t, [][]any{{"a", int64(-1)}, {"a", int64(2)}, {"b", int64(1)}}, gotTuples)
+	assert.Panics(t, func() { tuples.Set([]any{struct{}{}}, 0) })
+}
@@ -0,0 +1,52 @@
+package art
+
+// Set is a set of keys, a Tree[struct{}] with the methods of a set. Its leaves hold only the keys:
+// the empty value goes before the key in leaf, where it takes no space. Any Tree[struct{}] has the
+// same leaves, a Set saves no memory over it. The zero value is an empty set ready to use.
+type Set struct {
+	tree Tree[struct{}]
+}
+
+// Add adds key to the set, added is false if the key was already present.
+func (s *Set) Add(key Key) (added bool) {
+	return !s.tree.Insert(key, struct{}{})
+}
+
+// Contains reports whether key is in the set.
+func (s *Set) Contains(key Key) bool {
+	_, found := s.tree.Search(key)
+	return found
+}
+
+// Remove removes key from the set, removed is false if the key wasn't present.
+func (s *Set) Remove(key Key) (removed bool) {
+	removed, _ = s.tree.Remove(key)
+	return removed
+}
+
+// Len returns the number of keys in the set.
+func (s *Set) Len() int {
+	return s.tree.Len()
+}
+
+// Range calls fn for every key of the set in lexicographic order, until fn returns false.
+func (s *Set) Range(fn func(Key) bool) {
+	s.RangePrefix(nil, fn)
+}
+
+// RangePrefix calls fn for every key starting with prefix in lexicographic order, until fn returns false.
+func (s *Set) RangePrefix(prefix []byte, fn func(Key) bool) {
+	s.tree.ScanPrefix(prefix, func(key Key, _ struct{}) bool {
+		return fn(key)
+	})
+}
+
+// ContainsPrefix reports whether a key of the set starts with prefix.
+func (s *Set) ContainsPrefix(prefix []byte) bool {
+	return s.tree.PrefixIterator(prefix).Next()
+}
+
+// RemovePrefix removes all the keys starting with prefix and returns the number of removed keys.
+func (s *Set) RemovePrefix(prefix []byte) (removed int) {
+	return s.tree.DeletePrefix(prefix)
+}
@@ -0,0 +1,76 @@
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	s := Set{}
+	assert.False(t, s.Contains(Key("a")))
+	assert.True(t, s.Add(Key("a")))
+	assert.False(t, s.Add(Key("a")))
+	for _, key := range []string{"ab", "abc", "b", "ba"} {
+		assert.True(t, s.Add(Key(key)))
+	}
+	assert.True(t, s.Contains(Key("ab")))
+	assert.Equal(t, 5, s.Len())
+
+	var keys []string
+	s.Range(func(key Key) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	assert.Equal(t, []string{"a", "ab", "abc", "b", "ba"}, keys)
+	keys = keys[:0]
+	s.RangePrefix([]byte("ab"), func(key Key) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	assert.Equal(t, []string{"ab", "abc"}, keys)
+
+	assert.True(t, s.ContainsPrefix([]byte("ab")))
+	assert.False(t, s.ContainsPrefix([]byte("c")))
+	assert.Equal(t, 2, s.RemovePrefix([]byte("b")))
+	assert.False(t, s.ContainsPrefix([]byte("b")))
+
+	assert.True(t, s.Remove(Key("a")))
+	assert.False(t, s.Remove(Key("a")))
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestSet_LeafHoldsOnlyTheKey(t *testing.T) {
+	assert.Equal(t, unsafe.Sizeof(Key(nil)), unsafe.Sizeof(leaf[struct{}]{}))
+}
+
+// Memory used per key by a set, and by the Tree[struct{}] it wraps.
+// Their leaves have the same layout, the set uses no less memory than the tree.
+func BenchmarkSetMemory(b *testing.B) {
+	const size = 10000
+	keys := make([]Key, size)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint64(nil, uint64(i)*2654435761)
+	}
+	b.Run(fmt.Sprintf("set_%d", size), func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := Set{}
+			for _, key := range keys {
+				s.Add(key)
+			}
+		}
+	})
+	b.Run(fmt.Sprintf("tree_struct_%d", size), func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			tree := Tree[struct{}]{}
+			for _, key := range keys {
+				tree.Insert(key, struct{}{})
+			}
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package art
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshot is a read-only view of the tree frozen at the moment it was taken.
+//
+// The nodes reachable from a snapshot are never modified: writers copy the
+// nodes on the path they modify instead, so they never wait for the readers
+// of a snapshot, and snapshot reads don't need the optimistic locks.
+type Snapshot[T any] struct {
+	root node[T]
+
+	count sync.Once
+	size  int
+}
+
+// generation is an epoch of the tree. Every inner node belongs to the generation it was created
+// in, a snapshot freezes the current generation and all of its nodes at once: the writers check
+// it after locking a node, and copy a frozen node instead of modifying it.
+//
+// A node locked before it was frozen may still be modified by the writer holding it, a snapshot
+// reader waits for its lock before reading it, a frozen node never changes once it's unlocked.
+type generation struct {
+	frozen uint32
+}
+
+func (g *generation) isFrozen() bool {
+	return g != nil && atomic.LoadUint32(&g.frozen) == 1
+}
+
+// current returns the current generation of the tree, t.lock must be write locked.
+func (t *Tree[T]) current() *generation {
+	if t.epoch == nil {
+		t.epoch = &generation{}
+	}
+	return t.epoch
+}
+
+// Snapshot returns a point-in-time view of the tree. Taking a snapshot is O(1), it doesn't
+// wait for the writers, the nodes are copied lazily by the writers which modify them afterwards.
+// A write in flight is either entirely in the snapshot or not at all.
+func (t *Tree[T]) Snapshot() *Snapshot[T] {
+	t.lock.Lock()
+	s := &Snapshot[T]{root: t.root}
+	// the current generation is frozen and a new one starts
+	if t.epoch != nil {
+		atomic.StoreUint32(&t.epoch.frozen, 1)
+	}
+	t.epoch = &generation{}
+	t.lock.Unlock()
+	return s
+}
+
+// Len returns the number of keys in the snapshot, they are counted by the first call.
+func (s *Snapshot[T]) Len() int {
+	s.count.Do(func() {
+		if s.root == nil {
+			return
+		}
+		s.root.walk(func(n node[T], _ int) bool {
+			if n.isLeaf() {
+				s.size++
+			}
+			return true
+		}, 0)
+	})
+	return s.size
+}
+
+func (s *Snapshot[T]) Search(key Key) (value T, found bool) {
+	depth := 0
+	n := s.root
+	for {
+		switch current := n.(type) {
+		case *leaf[T]:
+			if current.cmp(key) {
+				return current.value, true
+			}
+			return value, false
+		case *inner[T]:
+			current.lock.wait()
+			if current.checkPrefix(key, depth) != min(current.prefixLen, maxPrefixLen) {
+				return value, false
+			}
+			nextDepth := depth + current.prefixLen
+			if len(key) <= nextDepth {
+				if l := current.leaf; l != nil && l.cmp(key) {
+					return l.value, true
+				}
+				return value, false
+			}
+			_, n = current.node.child(key[nextDepth])
+			depth = nextDepth + 1
+		default:
+			return value, false
+		}
+	}
+}
+
+// Walk visits all the nodes of the snapshot in depth first order, see Tree.Walk.
+// The snapshot never changes, it can be walked while the tree is modified.
+func (s *Snapshot[T]) Walk(fn func(NodeInfo) bool) {
+	walk(s.root, fn)
+}
+
+// Iterator in range (start, end] over the keys of the snapshot.
+func (s *Snapshot[T]) Iterator(start, end []byte) *iterator[T] {
+	return &iterator[T]{
+		cursor: s.Cursor(),
+		rng:    startEnd(start, end),
+		flip:   true,
+	}
+}
+
+// RangeIterator returns an iterator over the keys of the snapshot in r.
+func (s *Snapshot[T]) RangeIterator(r Range) *iterator[T] {
+	return &iterator[T]{
+		cursor: s.Cursor(),
+		rng:    r,
+	}
+}
@@ -0,0 +1,263 @@
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotItems[T any](s *Snapshot[T]) (keys []string, values []T) {
+	for iter := s.Iterator(nil, nil); iter.Next(); {
+		keys = append(keys, string(iter.Key()))
+		values = append(values, iter.Value())
+	}
+	return keys, values
+}
+
+func TestSnapshot(t *testing.T) {
+	tree := Tree[int]{}
+	keys := []string{"a", "ab", "abc", "abd", "b", "this_is_a_long_prefix::1", "this_is_a_long_prefix::2", "z"}
+	for i, key := range keys {
+		tree.Insert(Key(key), i)
+	}
+	snapshot := tree.Snapshot()
+
+	tree.Insert(Key("ab"), 100)
+	tree.Insert(Key("abe"), 101)
+	tree.Insert(Key("this_is_a_long"), 102)
+	tree.Remove(Key("abc"))
+	tree.Remove(Key("z"))
+	assert.Equal(t, 2, tree.DeletePrefix([]byte("this_is_a_long_prefix")))
+	tree.Remove(Key("a"))
+
+	require.Equal(t, len(keys), snapshot.Len())
+	for i, key := range keys {
+		value, found := snapshot.Search(Key(key))
+		assert.True(t, found, key)
+		assert.Equal(t, i, value, key)
+	}
+	for _, key := range []string{"abe", "this_is_a_long", "c", ""} {
+		_, found := snapshot.Search(Key(key))
+		assert.False(t, found, key)
+	}
+	got, values := snapshotItems(snapshot)
+	assert.Equal(t, keys, got)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, values)
+
+	var reversed []string
+	for iter := snapshot.Iterator(nil, nil).Reverse(); iter.Next(); {
+		reversed = append(reversed, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"z", "this_is_a_long_prefix::2", "this_is_a_long_prefix::1", "b", "abd", "abc", "ab", "a"}, reversed)
+
+	var ranged []string
+	for iter := snapshot.Iterator([]byte("ab"), []byte("b")); iter.Next(); {
+		ranged = append(ranged, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"abc", "abd", "b"}, ranged)
+
+	// the tree is changed
+	assert.Equal(t, 5, tree.Len())
+	for key, expected := range map[string]int{"ab": 100, "abd": 3, "abe": 101, "b": 4, "this_is_a_long": 102} {
+		value, found := tree.Search(Key(key))
+		assert.True(t, found, key)
+		assert.Equal(t, expected, value, key)
+	}
+	for _, key := range []string{"a", "abc", "z", "this_is_a_long_prefix::1"} {
+		_, found := tree.Search(Key(key))
+		assert.False(t, found, key)
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	tree := Tree[int]{}
+	snapshot := tree.Snapshot()
+	tree.Insert(Key("a"), 1)
+
+	assert.Equal(t, 0, snapshot.Len())
+	_, found := snapshot.Search(Key("a"))
+	assert.False(t, found)
+	assert.False(t, snapshot.Iterator(nil, nil).Next())
+}
+
+func TestSnapshotProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	alphabet := []byte{0, 1, 2, 'a'}
+	tree := Tree[int]{}
+	expected := map[string]int{}
+
+	type frozen struct {
+		snapshot *Snapshot[int]
+		expected map[string]int
+	}
+	var snapshots []frozen
+	for i := 0; i < 20_000; i++ {
+		key := randomBinaryKey(rng, alphabet)
+		switch op := rng.Intn(10); {
+		case op < 6:
+			tree.Insert(key, i)
+			expected[string(key)] = i
+		case op < 9:
+			tree.Remove(key)
+			delete(expected, string(key))
+		default:
+			tree.DeletePrefix(key)
+			for k := range expected {
+				if len(k) >= len(key) && k[:len(key)] == string(key) {
+					delete(expected, k)
+				}
+			}
+		}
+		if rng.Intn(200) == 0 {
+			copied := make(map[string]int, len(expected))
+			for k, v := range expected {
+				copied[k] = v
+			}
+			snapshots = append(snapshots, frozen{snapshot: tree.Snapshot(), expected: copied})
+		}
+	}
+	snapshots = append(snapshots, frozen{snapshot: tree.Snapshot(), expected: expected})
+
+	for _, f := range snapshots {
+		keys := make([]string, 0, len(f.expected))
+		for k := range f.expected {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		got, values := snapshotItems(f.snapshot)
+		require.Equal(t, len(keys), len(got))
+		require.Equal(t, len(keys), f.snapshot.Len())
+		for i, k := range keys {
+			require.Equal(t, k, got[i])
+			require.Equal(t, f.expected[k], values[i])
+			value, found := f.snapshot.Search(Key(k))
+			require.True(t, found)
+			require.Equal(t, f.expected[k], value)
+		}
+	}
+}
+
+func TestSnapshotConcurrentWriters(t *testing.T) {
+	t.Parallel()
+	const (
+		writers = 4
+		keys    = 256
+		rounds  = 50
+	)
+	tree := Tree[int]{}
+	key := func(w, k int) Key {
+		b := []byte(fmt.Sprintf("writer::%d::", w))
+		return binary.BigEndian.AppendUint16(b, uint16(k))
+	}
+	// every writer stores i into its key i%keys, a consistent snapshot holds
+	// consecutive values for the keys of a writer.
+	for w := 0; w < writers; w++ {
+		for k := 0; k < keys; k++ {
+			tree.Insert(key(w, k), k)
+		}
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := keys; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				tree.Insert(key(w, i%keys), i)
+			}
+		}(w)
+	}
+
+	for r := 0; r < rounds; r++ {
+		snapshot := tree.Snapshot()
+		got, values := snapshotItems(snapshot)
+		require.Len(t, got, writers*keys)
+		for w := 0; w < writers; w++ {
+			window := append([]int(nil), values[w*keys:(w+1)*keys]...)
+			sort.Ints(window)
+			for i := 1; i < len(window); i++ {
+				require.Equal(t, window[i-1]+1, window[i], "writer %d", w)
+			}
+		}
+		// the snapshot doesn't change while the writers keep going
+		again, againValues := snapshotItems(snapshot)
+		require.Equal(t, got, again)
+		require.Equal(t, values, againValues)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSnapshotInFlightWrite(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a"), 1)
+	tree.Insert(Key("b"), 2)
+	tree.Insert(Key("c"), 3)
+
+	// Snapshot doesn't wait for the write holding the node of "a"
+	locked, release := make(chan struct{}), make(chan struct{})
+	go tree.Compute(Key("a"), func(old int, _ bool) (int, Action) {
+		close(locked)
+		<-release
+		return old + 10, Store
+	})
+	<-locked
+	s := tree.Snapshot()
+
+	// the write in flight is in the snapshot, its readers wait for it
+	close(release)
+	value, _ := s.Search(Key("a"))
+	assert.Equal(t, 11, value)
+	assert.Equal(t, 3, s.Len())
+
+	// the writes after the snapshot aren't
+	tree.Insert(Key("a"), 100)
+	tree.Insert(Key("d"), 4)
+	value, _ = s.Search(Key("a"))
+	assert.Equal(t, 11, value)
+	keys, _ := snapshotItems(s)
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestSnapshot_WalkConcurrentWrites(t *testing.T) {
+	tree := Tree[int]{}
+	for i := 0; i < 1000; i++ {
+		tree.Insert(Key(fmt.Sprintf("key::%d", i)), i)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10_000; i++ {
+			key := Key(fmt.Sprintf("key::%d", i%2000))
+			if i%2 == 0 {
+				tree.Remove(key)
+			} else {
+				tree.Insert(key, i)
+			}
+		}
+	}()
+	for run := 0; run < 20; run++ {
+		snapshot := tree.Snapshot()
+		leaves := 0
+		snapshot.Walk(func(info NodeInfo) bool {
+			if info.Kind == Leaf {
+				leaves++
+			}
+			return true
+		})
+		require.Equal(t, snapshot.Len(), leaves)
+	}
+	<-done
+}
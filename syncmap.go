@@ -0,0 +1,58 @@
+package art
+
+// The methods below mirror the surface of sync.Map, the tree can be used as an
+// ordered sync.Map. Each of them is a single atomic operation on the key.
+
+// Load returns the value of key, the same as Search.
+func (t *Tree[T]) Load(key Key) (value T, ok bool) {
+	return t.Search(key)
+}
+
+// Store sets the value of key.
+func (t *Tree[T]) Store(key Key, value T) {
+	t.Insert(key, value)
+}
+
+// LoadOrStore returns the existing value of key if it is present. Otherwise,
+// it stores and returns the given value. loaded is true if the value was loaded.
+func (t *Tree[T]) LoadOrStore(key Key, value T) (actual T, loaded bool) {
+	t.Compute(key, func(old T, exists bool) (T, Action) {
+		if exists {
+			actual, loaded = old, true
+			return old, Keep
+		}
+		actual = value
+		return value, Store
+	})
+	return actual, loaded
+}
+
+// LoadAndDelete removes key and returns its previous value, loaded is true if the key was present.
+func (t *Tree[T]) LoadAndDelete(key Key) (value T, loaded bool) {
+	loaded, value = t.Remove(key)
+	return value, loaded
+}
+
+// Delete removes key.
+func (t *Tree[T]) Delete(key Key) {
+	t.Remove(key)
+}
+
+// Swap stores value for key and returns the previous value, loaded is true if the key was present.
+func (t *Tree[T]) Swap(key Key, value T) (previous T, loaded bool) {
+	t.Compute(key, func(old T, exists bool) (T, Action) {
+		previous, loaded = old, exists
+		return value, Store
+	})
+	return previous, loaded
+}
+
+// Range calls fn for every key of the tree in lexicographic order, until fn returns false.
+// Like sync.Map.Range it doesn't correspond to a consistent snapshot of the tree.
+func (t *Tree[T]) Range(fn func(key Key, value T) bool) {
+	for iter := t.Iterator(nil, nil); iter.Next(); {
+		if !fn(iter.Key(), iter.Value()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree_SyncMap(t *testing.T) {
+	tree := Tree[int]{}
+
+	actual, loaded := tree.LoadOrStore(Key("a"), 1)
+	assert.Equal(t, 1, actual)
+	assert.False(t, loaded)
+	actual, loaded = tree.LoadOrStore(Key("a"), 2)
+	assert.Equal(t, 1, actual)
+	assert.True(t, loaded)
+
+	previous, loaded := tree.Swap(Key("ab"), 3)
+	assert.Equal(t, 0, previous)
+	assert.False(t, loaded)
+	previous, loaded = tree.Swap(Key("ab"), 4)
+	assert.Equal(t, 3, previous)
+	assert.True(t, loaded)
+
+	tree.Store(Key("b"), 5)
+	value, ok := tree.Load(Key("b"))
+	assert.Equal(t, 5, value)
+	assert.True(t, ok)
+
+	var keys []string
+	tree.Range(func(key Key, value int) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	assert.Equal(t, []string{"a", "ab", "b"}, keys)
+	keys = keys[:0]
+	tree.Range(func(key Key, value int) bool {
+		keys = append(keys, string(key))
+		return false
+	})
+	assert.Equal(t, []string{"a"}, keys)
+
+	value, loaded = tree.LoadAndDelete(Key("ab"))
+	assert.Equal(t, 4, value)
+	assert.True(t, loaded)
+	value, loaded = tree.LoadAndDelete(Key("ab"))
+	assert.Equal(t, 0, value)
+	assert.False(t, loaded)
+
+	tree.Delete(Key("a"))
+	_, ok = tree.Load(Key("a"))
+	assert.False(t, ok)
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestTree_ConcurrentLoadOrStore(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 8
+		keys    = 1000
+	)
+	tree := Tree[int]{}
+	winners := make([][]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := 0; k < keys; k++ {
+				actual, loaded := tree.LoadOrStore(Key(fmt.Sprintf("key::%d", k)), w)
+				if !loaded {
+					assert.Equal(t, w, actual)
+					winners[w] = append(winners[w], k)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// the first writer of every key wins, exactly once
+	stored := 0
+	for w, won := range winners {
+		for _, k := range won {
+			value, found := tree.Load(Key(fmt.Sprintf("key::%d", k)))
+			require.True(t, found)
+			require.Equal(t, w, value)
+		}
+		stored += len(won)
+	}
+	require.Equal(t, keys, stored)
+	require.Equal(t, keys, tree.Len())
+}
+
+func TestTree_ConcurrentSwap(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 8
+		swaps   = 1000
+	)
+	tree := Tree[int]{}
+	// every value is swapped in and out once, no value is lost or seen twice
+	seen := make([][]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= swaps; i++ {
+				if previous, loaded := tree.Swap(Key("key"), w*swaps+i); loaded {
+					seen[w] = append(seen[w], previous)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	last, loaded := tree.LoadAndDelete(Key("key"))
+	require.True(t, loaded)
+	values := map[int]bool{last: true}
+	for _, previous := range seen {
+		for _, v := range previous {
+			require.False(t, values[v])
+			values[v] = true
+		}
+	}
+	require.Len(t, values, workers*swaps)
+	require.Equal(t, 0, tree.Len())
+}
@@ -1,14 +1,42 @@
 package art
 
-import "sync/atomic"
+import (
+	"bytes"
+	"sync/atomic"
+)
 
 type Tree[T any] struct {
 	lock olock
 	root node[T]
 	size int64
+
+	// epoch is the current generation, it's replaced with the lock held by every snapshot. Inner nodes
+	// of the earlier generations are frozen, they are shared with the snapshots and copied before they are modified.
+	epoch *generation
+	// watchers is replaced with the lock held by every change of the watchers, the writers load it without locking
+	watchers atomic.Pointer[[]*watcher[T]]
+}
+
+// thaw replaces the root frozen by a snapshot with a copy owned by the current epoch.
+// The operation is restarted afterwards, whether the root has been replaced or not.
+func (t *Tree[T]) thaw(root *inner[T], version uint64) {
+	if t.lock.Upgrade(version, nil) {
+		return
+	}
+	root.lock.Lock()
+	t.root = root.clone(t.current())
+	root.lock.UnlockObsolete()
+	t.lock.Unlock()
 }
 
 func (t *Tree[T]) Insert(key Key, value T) (updated bool) {
+	if t.watched(key) {
+		t.compute(key, t.watch(key, func(old *leaf[T]) (T, Action) {
+			updated = old != nil
+			return value, Store
+		}))
+		return updated
+	}
 	for {
 		version, restart := t.lock.RLock()
 		l := &leaf[T]{key: key, value: value}
@@ -27,12 +55,19 @@ func (t *Tree[T]) Insert(key Key, value T) (updated bool) {
 				continue // restart
 			}
 			t.root, _, updated = root.insert(l, 0, &t.lock, version)
+			if nn, ok := t.root.(*inner[T]); ok {
+				nn.epoch = t.current()
+			}
 			t.lock.Unlock()
 			if !updated {
 				atomic.AddInt64(&t.size, 1)
 			}
 			return
 		}
+		if n := root.(*inner[T]); n.epoch != t.epoch {
+			t.thaw(n, version)
+			continue
+		}
 		_, restart, updated = root.insert(l, 0, &t.lock, version)
 		if restart {
 			continue
@@ -63,7 +98,43 @@ func (t *Tree[T]) Search(key Key) (value T, found bool) {
 	}
 }
 
+// LongestPrefix returns the longest key in the tree which is a prefix of key,
+// key itself included. It is a single descent following key down the tree.
+func (t *Tree[T]) LongestPrefix(key Key) (matchedKey Key, value T, ok bool) {
+	for {
+		version, _ := t.lock.RLock()
+		var match *leaf[T]
+		if root, ok := t.root.(*inner[T]); ok {
+			// the descent releases the lock of the tree
+			var restart bool
+			if match, restart = root.longestPrefix(key, 0, &t.lock, version); restart {
+				continue
+			}
+		} else {
+			if root, ok := t.root.(*leaf[T]); ok && bytes.HasPrefix(key, root.key) {
+				match = root
+			}
+			if t.lock.RUnlock(version, nil) {
+				continue
+			}
+		}
+		if match == nil {
+			return nil, value, false
+		}
+		return match.key, match.value, true
+	}
+}
+
 func (t *Tree[T]) Remove(key Key) (deleted bool, value T) {
+	if t.watched(key) {
+		t.compute(key, t.watch(key, func(old *leaf[T]) (T, Action) {
+			if deleted = old != nil; deleted {
+				value = old.value
+			}
+			return value, Delete
+		}))
+		return deleted, value
+	}
 	restart := false
 	var deletedNode node[T]
 	for {
@@ -84,6 +155,7 @@ func (t *Tree[T]) Remove(key Key) (deleted bool, value T) {
 			value = l.value
 			t.root = nil
 			t.lock.Unlock()
+			atomic.AddInt64(&t.size, -1)
 			return true, value
 		} else if isLeaf { // mismatch
 			if t.lock.RUnlock(version, nil) {
@@ -91,6 +163,10 @@ func (t *Tree[T]) Remove(key Key) (deleted bool, value T) {
 			}
 			return false, value
 		}
+		if n := root.(*inner[T]); n.epoch != t.epoch {
+			t.thaw(n, version)
+			continue
+		}
 
 		if deleted, restart, deletedNode = root.del(key, 0, &t.lock, version, func(rn node[T]) {
 			t.root = rn
@@ -105,6 +181,190 @@ func (t *Tree[T]) Remove(key Key) (deleted bool, value T) {
 	}
 }
 
+// Action tells Compute what to do with the key.
+type Action uint8
+
+const (
+	// Keep leaves the key as it is
+	Keep Action = iota
+	// Store sets the value returned by the callback
+	Store
+	// Delete removes the key
+	Delete
+)
+
+// Compute atomically calls fn with the current value of key and applies the action it returns.
+// fn is called exactly once, while the node holding the key is write locked, so no
+// other write to the key can happen in between. fn must not access the tree.
+// It returns the value of the key after the action, and whether the key is present.
+func (t *Tree[T]) Compute(key Key, fn func(old T, exists bool) (value T, action Action)) (value T, ok bool) {
+	update := func(old *leaf[T]) (T, Action) {
+		var current T
+		if old != nil {
+			current = old.value
+		}
+		stored, action := fn(current, old != nil)
+		switch {
+		case action == Store:
+			value, ok = stored, true
+		case action == Keep && old != nil:
+			value, ok = current, true
+		}
+		return stored, action
+	}
+	t.compute(key, t.watch(key, update))
+	return value, ok
+}
+
+// compute applies fn to the leaf of key, descending from the root of the tree.
+func (t *Tree[T]) compute(key Key, fn func(*leaf[T]) (T, Action)) {
+	for {
+		version, _ := t.lock.RLock()
+		delta := 0
+		switch root := t.root.(type) {
+		case *inner[T]:
+			if root.epoch != t.epoch {
+				t.thaw(root, version)
+				continue
+			}
+			update := func(rn node[T]) {
+				t.root = rn
+			}
+			var restart bool
+			if delta, restart = root.compute(key, 0, &t.lock, version, update, fn); restart {
+				continue
+			}
+		default:
+			// the tree is empty or a single leaf
+			if t.lock.Upgrade(version, nil) {
+				continue
+			}
+			var old *leaf[T]
+			if l, ok := root.(*leaf[T]); ok && l.cmp(key) {
+				old = l
+			}
+			stored, action := fn(old)
+			switch {
+			case action == Store && (root == nil || old != nil):
+				t.root = &leaf[T]{key: key, value: stored}
+				if old == nil {
+					delta = 1
+				}
+			case action == Store:
+				expanded, _, _ := root.insert(&leaf[T]{key: key, value: stored}, 0, &t.lock, version)
+				expanded.(*inner[T]).epoch = t.current()
+				t.root = expanded
+				delta = 1
+			case action == Delete && old != nil:
+				t.root = nil
+				delta = -1
+			}
+			t.lock.Unlock()
+		}
+		atomic.AddInt64(&t.size, int64(delta))
+		return
+	}
+}
+
+// Len returns the number of keys stored in the tree.
+func (t *Tree[T]) Len() int {
+	return int(atomic.LoadInt64(&t.size))
+}
+
+// DeletePrefix removes all the keys starting with prefix and returns the number of removed keys.
+// The subtree covering the prefix is unlinked from the tree at once, instead of
+// removing the keys one by one. Its nodes are marked obsolete afterwards, so that
+// concurrent operations still holding them restart and see the tree without it.
+func (t *Tree[T]) DeletePrefix(prefix []byte) (removed int) {
+	var unlink func(node[T])
+	if t.watchedPrefix(prefix) {
+		// the subtree is drained before it's unlinked, the watchers get the deletes
+		// before the keys can be written again
+		unlink = func(detached node[T]) {
+			removed = drain(detached, func(l *leaf[T]) {
+				var value T
+				t.notify(l.key, l, value, Delete)
+			})
+		}
+	}
+	for {
+		version, _ := t.lock.RLock()
+		root := t.root
+
+		var covered bool
+		switch n := root.(type) {
+		case *leaf[T]:
+			covered = bytes.HasPrefix(n.key, prefix)
+		case *inner[T]:
+			if n.prefixLen < len(prefix) {
+				if n.epoch != t.epoch {
+					t.thaw(n, version)
+					continue
+				}
+				detached, restart := n.delPrefix(prefix, 0, &t.lock, version, func(rn node[T]) {
+					t.root = rn
+				}, unlink)
+				if restart {
+					continue
+				}
+				if detached != nil && unlink == nil {
+					removed = drain(detached, nil)
+				}
+				atomic.AddInt64(&t.size, -int64(removed))
+				return removed
+			}
+			covered = n.prefixMismatch(prefix, 0) >= len(prefix)
+		}
+
+		if !covered {
+			if t.lock.RUnlock(version, nil) {
+				continue
+			}
+			return 0
+		}
+		// the whole tree is covered by the prefix
+		if t.lock.Upgrade(version, nil) {
+			continue
+		}
+		if unlink != nil {
+			unlink(root)
+		}
+		t.root = nil
+		t.lock.Unlock()
+		if unlink == nil {
+			removed = drain(root, nil)
+		}
+		atomic.AddInt64(&t.size, -int64(removed))
+		return removed
+	}
+}
+
+// Walk visits all the nodes of the tree in depth first order, the inner nodes
+// included, until fn returns false. Walk reads the nodes without locking them and
+// must not run concurrently with writers, walk a Snapshot of a tree in use instead.
+func (t *Tree[T]) Walk(fn func(NodeInfo) bool) {
+	walk(t.root, fn)
+}
+
+func walk[T any](root node[T], fn func(NodeInfo) bool) {
+	if root == nil {
+		return
+	}
+	root.walk(func(n node[T], depth int) bool {
+		info := NodeInfo{Kind: n.Kind(), Depth: depth}
+		switch current := n.(type) {
+		case *leaf[T]:
+			info.Key = current.key
+		case *inner[T]:
+			// the prefix is copied, fn must not see the array of a live node
+			info.Prefix = append([]byte{}, current.prefix[:min(current.prefixLen, maxPrefixLen)]...)
+			info.PrefixLen = current.prefixLen
+			info.Children = current.node.size()
+		}
+		return fn(info)
+	}, 0)
+}
+
 func (t *Tree[T]) Empty() (empty bool) {
 	for {
 		version, _ := t.lock.RLock()
@@ -116,14 +376,43 @@ func (t *Tree[T]) Empty() (empty bool) {
 	}
 }
 
-// Iterator in range (start, end].
+// Iterator in range (start, end], or [start, end) once reversed. An empty bound leaves the range open.
 // Iterator is concurrently safe, but doesn't guarantee to provide consistent
-// snapshot of the tree state.
+// snapshot of the tree state, use Snapshot for that.
 func (t *Tree[T]) Iterator(start, end []byte) *iterator[T] {
 	return &iterator[T]{
-		tree:      t,
-		cursor:    start,
-		terminate: end,
+		cursor: t.Cursor(),
+		rng:    startEnd(start, end),
+		flip:   true,
+	}
+}
+
+// RangeIterator returns an iterator over the keys in r, the iteration starts
+// right at the bound of the range, in both directions.
+func (t *Tree[T]) RangeIterator(r Range) *iterator[T] {
+	return &iterator[T]{
+		cursor: t.Cursor(),
+		rng:    r,
+	}
+}
+
+// PrefixIterator returns an iterator over the keys starting with prefix.
+// The iteration starts from the inner node covering the prefix instead of
+// the root of the tree, it shares the concurrency guarantees of Iterator.
+func (t *Tree[T]) PrefixIterator(prefix []byte) *iterator[T] {
+	return &iterator[T]{
+		cursor: t.Cursor(),
+		prefix: prefix,
+	}
+}
+
+// ScanPrefix calls fn for every key starting with prefix in lexicographic order,
+// the scan is terminated if fn returns false.
+func (t *Tree[T]) ScanPrefix(prefix []byte, fn func(Key, T) bool) {
+	for iter := t.PrefixIterator(prefix); iter.Next(); {
+		if !fn(iter.Key(), iter.Value()) {
+			return
+		}
 	}
 }
 
@@ -134,3 +134,71 @@ func BenchmarkReadWriteSyncMap(b *testing.B) {
 		})
 	}
 }
+
+// Sorted writes, applied in batches or one by one.
+func BenchmarkArtApplyBatch(b *testing.B) {
+	value := newValue(123)
+	for _, size := range []int{16, 256} {
+		b.Run(fmt.Sprintf("batch_%d", size), func(b *testing.B) {
+			l := NewArtTree()
+			ops := make([]Op[Value], size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i += size {
+				for k := range ops {
+					key := make([]byte, 8)
+					binary.BigEndian.PutUint64(key, uint64(i+k))
+					ops[k] = Op[Value]{Key: key, Value: value}
+				}
+				l.ApplyBatch(ops)
+			}
+		})
+		b.Run(fmt.Sprintf("insert_%d", size), func(b *testing.B) {
+			l := NewArtTree()
+			keys := make([]Key, size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i += size {
+				for k := range keys {
+					key := make([]byte, 8)
+					binary.BigEndian.PutUint64(key, uint64(i+k))
+					keys[k] = key
+				}
+				for _, key := range keys {
+					l.Insert(key, value)
+				}
+			}
+		})
+	}
+
+	// updates of the keys of a loaded tree, in sorted runs of neighbouring keys
+	const loaded = 1 << 16
+	keys := make([]Key, loaded)
+	ops := make([]Op[Value], loaded)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint64(nil, uint64(i))
+		ops[i] = Op[Value]{Key: keys[i], Value: value}
+	}
+	for _, size := range []int{16, 256} {
+		b.Run(fmt.Sprintf("update_batch_%d", size), func(b *testing.B) {
+			l := NewArtTree()
+			l.ApplyBatch(ops)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i += size {
+				start := i % loaded
+				l.ApplyBatch(ops[start : start+size])
+			}
+		})
+		b.Run(fmt.Sprintf("update_insert_%d", size), func(b *testing.B) {
+			l := NewArtTree()
+			l.ApplyBatch(ops)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i += size {
+				start := i % loaded
+				for _, key := range keys[start : start+size] {
+					l.Insert(key, value)
+				}
+			}
+		})
+	}
+}
@@ -69,6 +69,118 @@ func TestTree_ConcurrentInsert2(t *testing.T) {
 	}
 }
 
+func TestTree_ConcurrentLen(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 16
+		ops     = 100_000
+		keys    = 4096
+	)
+	tree := Tree[int]{}
+	refs := make([]map[string]int, workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		refs[w] = map[string]int{}
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ref := refs[w]
+			rng := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < ops; i++ {
+				// every worker owns a disjoint set of keys, but all of them share the
+				// same inner nodes, so inserts and removes keep growing, shrinking and
+				// collapsing the nodes under each other.
+				key := fmt.Sprintf("sharedNode::%d::%d", rng.Intn(keys), w)
+				if rng.Intn(3) == 0 {
+					deleted, _ := tree.Remove(Key(key))
+					_, exist := ref[key]
+					assert.Equal(t, exist, deleted)
+					delete(ref, key)
+				} else {
+					updated := tree.Insert(Key(key), i)
+					_, exist := ref[key]
+					assert.Equal(t, exist, updated)
+					ref[key] = i
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	expected := 0
+	for _, ref := range refs {
+		expected += len(ref)
+		for key, value := range ref {
+			got, found := tree.Search(Key(key))
+			assert.True(t, found)
+			assert.Equal(t, value, got)
+		}
+	}
+	assert.Equal(t, expected, tree.Len())
+
+	// drain the tree and check the counter follows it down to zero
+	for _, ref := range refs {
+		for key := range ref {
+			deleted, _ := tree.Remove(Key(key))
+			assert.True(t, deleted)
+		}
+	}
+	assert.Equal(t, 0, tree.Len())
+	assert.True(t, tree.Empty())
+}
+
+func TestTree_ConcurrentDeletePrefix(t *testing.T) {
+	t.Parallel()
+	const (
+		tenants = 8
+		keys    = 10_000
+	)
+	tree := Tree[int]{}
+	wg := sync.WaitGroup{}
+	for tenant := 0; tenant < tenants; tenant++ {
+		wg.Add(1)
+		go func(tenant int) {
+			defer wg.Done()
+			prefix := fmt.Sprintf("tenant/%d/", tenant)
+			for run := 0; run < 5; run++ {
+				for i := 0; i < keys; i++ {
+					tree.Insert(Key(fmt.Sprintf("%s%d", prefix, i)), i)
+				}
+				if tenant%2 == 0 && run == 4 {
+					// keep the half of tenants
+					return
+				}
+				assert.Equal(t, keys, tree.DeletePrefix([]byte(prefix)))
+				_, found := tree.Search(Key(prefix + "0"))
+				assert.False(t, found)
+			}
+		}(tenant)
+	}
+	// concurrent readers of the kept tenants
+	for tenant := 0; tenant < tenants; tenant += 2 {
+		wg.Add(1)
+		go func(tenant int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(tenant)))
+			for i := 0; i < keys; i++ {
+				tree.Search(Key(fmt.Sprintf("tenant/%d/%d", tenant, rng.Intn(keys))))
+			}
+		}(tenant)
+	}
+	wg.Wait()
+
+	assert.Equal(t, tenants/2*keys, tree.Len())
+	for tenant := 0; tenant < tenants; tenant++ {
+		for i := 0; i < keys; i++ {
+			value, found := tree.Search(Key(fmt.Sprintf("tenant/%d/%d", tenant, i)))
+			assert.Equal(t, tenant%2 == 0, found)
+			if found {
+				assert.Equal(t, i, value)
+			}
+		}
+	}
+}
+
 func BenchmarkArtConcurrentInsert(b *testing.B) {
 	value := newValue(123)
 	l := Tree[[]byte]{}
@@ -121,3 +233,38 @@ func BenchmarkBtreeConcurrentInsert(b *testing.B) {
 		}
 	})
 }
+
+func TestTree_ConcurrentCompute(t *testing.T) {
+	t.Parallel()
+	const (
+		workers    = 8
+		increments = 2000
+		counters   = 16
+	)
+	tree := Tree[int]{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				tree.Compute(Key(fmt.Sprintf("counter::%d", i%counters)), func(old int, exists bool) (int, Action) {
+					return old + 1, Store
+				})
+				// the nodes of the counters keep being split and collapsed
+				key := Key(fmt.Sprintf("counter::%d::%d", i%counters, w))
+				tree.Insert(key, i)
+				tree.Remove(key)
+			}
+		}(w)
+	}
+	wg.Wait()
+	total := 0
+	for c := 0; c < counters; c++ {
+		value, found := tree.Search(Key(fmt.Sprintf("counter::%d", c)))
+		assert.True(t, found)
+		total += value
+	}
+	assert.Equal(t, workers*increments, total)
+	assert.Equal(t, counters, tree.Len())
+}
@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tidwall/btree"
+	"math/rand"
 	"os"
+	"sort"
 	"testing"
 )
 
@@ -37,7 +41,7 @@ func TestArtTree_Insert(t *testing.T) {
 	assert.Equal(t, Value("I'm Value"), value)
 }
 
-type Set struct {
+type keyValue struct {
 	key   Key
 	value Value
 }
@@ -58,7 +62,7 @@ func TestArtTree_InsertLongKey(t *testing.T) {
 
 func TestArtTree_Insert2(t *testing.T) {
 	tree := NewArtTree()
-	sets := []Set{{
+	sets := []keyValue{{
 		Key("sharedKey::1"), Value("value1"),
 	}, {
 		Key("sharedKey::2"), Value("value2"),
@@ -179,6 +183,40 @@ func TestArtTree_Remove(t *testing.T) {
 	assert.True(t, deleted)
 }
 
+func TestTree_Len(t *testing.T) {
+	tree := NewArtTree()
+	assert.Equal(t, 0, tree.Len())
+
+	// root leaf
+	tree.Insert(Key("sharedKey::1"), Value("value1"))
+	assert.Equal(t, 1, tree.Len())
+	tree.Insert(Key("sharedKey::1"), Value("value1 updated"))
+	assert.Equal(t, 1, tree.Len())
+	deleted, _ := tree.Remove(Key("sharedKey::1"))
+	assert.True(t, deleted)
+	assert.Equal(t, 0, tree.Len())
+	assert.True(t, tree.Empty())
+
+	// inner nodes
+	tree.Insert(Key("sharedKey::1"), Value("value1"))
+	tree.Insert(Key("sharedKey::2"), Value("value2"))
+	tree.Insert(Key("sharedKey::1::name"), Value("name_value1"))
+	assert.Equal(t, 3, tree.Len())
+	tree.Insert(Key("sharedKey::2"), Value("value2 updated"))
+	assert.Equal(t, 3, tree.Len())
+	deleted, _ = tree.Remove(Key("sharedKey::3"))
+	assert.False(t, deleted)
+	assert.Equal(t, 3, tree.Len())
+
+	// collapse down to the root leaf and remove it
+	for _, key := range []string{"sharedKey::1::name", "sharedKey::2", "sharedKey::1"} {
+		deleted, _ = tree.Remove(Key(key))
+		assert.True(t, deleted)
+	}
+	assert.Equal(t, 0, tree.Len())
+	assert.True(t, tree.Empty())
+}
+
 func TestArtTree_Search(t *testing.T) {
 	tree := NewArtTree()
 	value, found := tree.Search(Key("wrong-key"))
@@ -207,7 +245,7 @@ func TestArtTree_Search(t *testing.T) {
 
 func TestArtTree_Remove2(t *testing.T) {
 	tree := NewArtTree()
-	sets := []Set{{
+	sets := []keyValue{{
 		Key("012345678:-1"), Value("value1"),
 	}, {
 		Key("012345678:-2"), Value("value2"),
@@ -237,6 +275,372 @@ func TestArtTree_Remove2(t *testing.T) {
 
 }
 
+func TestArtTree_PrefixKeys(t *testing.T) {
+	tree := NewArtTree()
+	keys := []Key{
+		Key("a"),
+		Key("a\x00"),
+		Key("a\x00\x00"),
+		Key("a\x00b"),
+		Key("ab"),
+		Key(""),
+		Key("\x00"),
+		Key("this is a long prefix"),
+		Key("this is a long prefix\x00"),
+		Key("this is a long"),
+	}
+	for _, key := range keys {
+		assert.False(t, tree.Insert(key, Value(key)))
+	}
+	assert.Equal(t, len(keys), tree.Len())
+	for _, key := range keys {
+		value, found := tree.Search(key)
+		assert.Truef(t, found, "should found %q", key)
+		assert.Equal(t, Value(key), value)
+	}
+	for _, key := range []Key{Key("a\x00\x00\x00"), Key("this is a"), Key("\x00\x00"), Key("b")} {
+		_, found := tree.Search(key)
+		assert.Falsef(t, found, "should not found %q", key)
+	}
+
+	// remove the keys in insertion order, the rest of keys must stay
+	for i, key := range keys {
+		deleted, value := tree.Remove(key)
+		assert.Truef(t, deleted, "should delete %q", key)
+		assert.Equal(t, Value(key), value)
+		for _, rest := range keys[i+1:] {
+			value, found := tree.Search(rest)
+			assert.Truef(t, found, "should found %q after deleting %q", rest, key)
+			assert.Equal(t, Value(rest), value)
+		}
+	}
+	assert.Equal(t, 0, tree.Len())
+	assert.True(t, tree.Empty())
+}
+
+// randomBinaryKey returns a short key over a small alphabet including 0x00,
+// so that keys are frequently the prefixes of each other.
+func randomBinaryKey(rng *rand.Rand, alphabet []byte) Key {
+	var key Key
+	if rng.Intn(4) == 0 {
+		// exceed the max prefix len stored in the nodes
+		key = append(key, "shared long prefix::"...)
+	}
+	for i := rng.Intn(6); i > 0; i-- {
+		key = append(key, alphabet[rng.Intn(len(alphabet))])
+	}
+	return key
+}
+
+func TestArtTree_BinaryKeysProperty(t *testing.T) {
+	for _, alphabet := range [][]byte{
+		{0x00, 0x01, 0xff},
+		{0x00, 0x01, 0x02, 0x03, 0x10, 0x20, 0x7f, 0x80, 0xfe, 0xff},
+	} {
+		for seed := int64(0); seed < 20; seed++ {
+			rng := rand.New(rand.NewSource(seed))
+			tree := NewArtTree()
+			ref := map[string]Value{}
+			for i := 0; i < 2000; i++ {
+				key := randomBinaryKey(rng, alphabet)
+				if rng.Intn(3) == 0 {
+					deleted, value := tree.Remove(key)
+					expected, exist := ref[string(key)]
+					require.Equalf(t, exist, deleted, "seed %d, remove %q", seed, key)
+					require.Equal(t, expected, value)
+					delete(ref, string(key))
+				} else {
+					value := Value(fmt.Sprint(i))
+					updated := tree.Insert(key, value)
+					_, exist := ref[string(key)]
+					require.Equalf(t, exist, updated, "seed %d, insert %q", seed, key)
+					ref[string(key)] = value
+				}
+			}
+			require.Equal(t, len(ref), tree.Len())
+
+			sorted := make([]string, 0, len(ref))
+			for key, value := range ref {
+				sorted = append(sorted, key)
+				got, found := tree.Search(Key(key))
+				require.Truef(t, found, "seed %d, search %q", seed, key)
+				require.Equal(t, value, got)
+			}
+			sort.Strings(sorted)
+
+			iterated := []string{}
+			for iter := tree.Iterator(nil, nil); iter.Next(); {
+				iterated = append(iterated, string(iter.Key()))
+				require.Equal(t, ref[string(iter.Key())], iter.Value())
+			}
+			require.Equalf(t, sorted, iterated, "seed %d", seed)
+
+			reversed := []string{}
+			for iter := tree.Iterator(nil, nil).Reverse(); iter.Next(); {
+				reversed = append(reversed, string(iter.Key()))
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+			require.Equalf(t, sorted, reversed, "seed %d", seed)
+		}
+	}
+}
+
+func TestTree_DeletePrefix(t *testing.T) {
+	keys := []string{
+		"tenant",
+		"tenant/1",
+		"tenant/1/a",
+		"tenant/1/b",
+		"tenant/12/a",
+		"tenant/2/a",
+		"tenant/2/b/c",
+		"tenant/2/b/d",
+		"tenant/a very long namespace",
+		"tenant/a very long namespace/1",
+		"tenant/a very long namespace/2",
+		"user/1",
+	}
+	for _, tc := range []struct {
+		desc    string
+		keys    []string
+		prefix  string
+		removed []string
+	}{
+		{
+			desc:   "empty tree",
+			prefix: "tenant",
+		},
+		{
+			desc:    "root leaf",
+			keys:    keys[:1],
+			prefix:  "ten",
+			removed: keys[:1],
+		},
+		{
+			desc:   "root leaf mismatch",
+			keys:   keys[:1],
+			prefix: "tenant/",
+		},
+		{
+			desc:    "empty prefix",
+			keys:    keys,
+			removed: keys,
+		},
+		{
+			desc:    "prefix covers the root",
+			keys:    keys[:11],
+			prefix:  "ten",
+			removed: keys[:11],
+		},
+		{
+			desc:    "prefix ends at a node",
+			keys:    keys,
+			prefix:  "tenant/1/",
+			removed: []string{"tenant/1/a", "tenant/1/b"},
+		},
+		{
+			desc:    "prefix is a key",
+			keys:    keys,
+			prefix:  "tenant/1",
+			removed: []string{"tenant/1", "tenant/1/a", "tenant/1/b", "tenant/12/a"},
+		},
+		{
+			desc:    "prefix ends inside the prefix of a node",
+			keys:    keys,
+			prefix:  "tenant/2/b/",
+			removed: []string{"tenant/2/b/c", "tenant/2/b/d"},
+		},
+		{
+			desc:    "single leaf",
+			keys:    keys,
+			prefix:  "tenant/2/a",
+			removed: []string{"tenant/2/a"},
+		},
+		{
+			desc:    "long prefix",
+			keys:    keys,
+			prefix:  "tenant/a very long",
+			removed: []string{"tenant/a very long namespace", "tenant/a very long namespace/1", "tenant/a very long namespace/2"},
+		},
+		{
+			desc:   "long prefix mismatch",
+			keys:   keys,
+			prefix: "tenant/a very short",
+		},
+		{
+			desc:   "no matching child",
+			keys:   keys,
+			prefix: "tenant/3",
+		},
+	} {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			var tree Tree[string]
+			for _, key := range tc.keys {
+				tree.Insert(Key(key), key)
+			}
+			removed := map[string]bool{}
+			for _, key := range tc.removed {
+				removed[key] = true
+			}
+			require.Equal(t, len(tc.removed), tree.DeletePrefix([]byte(tc.prefix)))
+			require.Equal(t, len(tc.keys)-len(tc.removed), tree.Len())
+			for _, key := range tc.keys {
+				value, found := tree.Search(Key(key))
+				require.Equalf(t, !removed[key], found, "search %q", key)
+				if found {
+					require.Equal(t, key, value)
+				}
+			}
+			// the tree keeps working after the removal
+			for _, key := range tc.keys {
+				tree.Insert(Key(key), key)
+			}
+			require.Equal(t, len(tc.keys), tree.Len())
+		})
+	}
+}
+
+func TestTree_DeletePrefixProperty(t *testing.T) {
+	alphabet := []byte{0x00, 0x01, 0x02, 0x10, 0x7f, 0xff}
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		tree := NewArtTree()
+		ref := map[string]Value{}
+		for i := 0; i < 500; i++ {
+			key := randomBinaryKey(rng, alphabet)
+			tree.Insert(key, Value(key))
+			ref[string(key)] = Value(key)
+		}
+		for i := 0; i < 20; i++ {
+			prefix := randomBinaryKey(rng, alphabet)
+			expected := 0
+			for key := range ref {
+				if bytes.HasPrefix([]byte(key), prefix) {
+					delete(ref, key)
+					expected++
+				}
+			}
+			require.Equalf(t, expected, tree.DeletePrefix(prefix), "seed %d, prefix %q", seed, prefix)
+			require.Equal(t, len(ref), tree.Len())
+		}
+		for key, value := range ref {
+			got, found := tree.Search(Key(key))
+			require.Truef(t, found, "seed %d, search %q", seed, key)
+			require.Equal(t, value, got)
+		}
+		count := 0
+		for iter := tree.Iterator(nil, nil); iter.Next(); count++ {
+			require.Contains(t, ref, string(iter.Key()))
+		}
+		require.Equal(t, len(ref), count)
+	}
+}
+
+func TestTree_LongestPrefix(t *testing.T) {
+	var tree Tree[string]
+	_, _, ok := tree.LongestPrefix(Key("/api"))
+	assert.False(t, ok)
+
+	tree.Insert(Key("/"), "/")
+	key, value, ok := tree.LongestPrefix(Key("/api/v1"))
+	assert.True(t, ok)
+	assert.Equal(t, Key("/"), key)
+	assert.Equal(t, "/", value)
+	_, _, ok = tree.LongestPrefix(Key(""))
+	assert.False(t, ok)
+
+	for _, route := range []string{
+		"/api",
+		"/api/",
+		"/api/v1/users",
+		"/api/v1/users/",
+		"/api/v2",
+		"/static/a very long path to the assets/",
+	} {
+		tree.Insert(Key(route), route)
+	}
+	for _, tc := range []struct {
+		path  string
+		route string
+	}{
+		{path: "/", route: "/"},
+		{path: "/index.html", route: "/"},
+		{path: "/ap", route: "/"},
+		{path: "/api", route: "/api"},
+		{path: "/api2", route: "/api"},
+		{path: "/api/v1", route: "/api/"},
+		{path: "/api/v1/users", route: "/api/v1/users"},
+		{path: "/api/v1/users/42", route: "/api/v1/users/"},
+		{path: "/api/v1/usersx", route: "/api/v1/users"},
+		{path: "/api/v2/users", route: "/api/v2"},
+		{path: "/static/a very long path to the assets/logo.png", route: "/static/a very long path to the assets/"},
+		{path: "/static/a very long path to the other/logo.png", route: "/"},
+	} {
+		key, value, ok := tree.LongestPrefix(Key(tc.path))
+		assert.Truef(t, ok, "path %q", tc.path)
+		assert.Equalf(t, Key(tc.route), key, "path %q", tc.path)
+		assert.Equalf(t, tc.route, value, "path %q", tc.path)
+	}
+}
+
+func TestTree_LongestPrefixIP(t *testing.T) {
+	var tree Tree[string]
+	// routes keyed by the significant bytes of IPv4 prefixes
+	tree.Insert(Key{10}, "10.0.0.0/8")
+	tree.Insert(Key{10, 0}, "10.0.0.0/16")
+	tree.Insert(Key{10, 0, 0}, "10.0.0.0/24")
+	tree.Insert(Key{10, 1}, "10.1.0.0/16")
+	tree.Insert(Key{192, 168}, "192.168.0.0/16")
+
+	for _, tc := range []struct {
+		ip    Key
+		route string
+	}{
+		{ip: Key{10, 0, 0, 1}, route: "10.0.0.0/24"},
+		{ip: Key{10, 0, 1, 1}, route: "10.0.0.0/16"},
+		{ip: Key{10, 1, 0, 0}, route: "10.1.0.0/16"},
+		{ip: Key{10, 2, 0, 0}, route: "10.0.0.0/8"},
+		{ip: Key{192, 168, 0, 1}, route: "192.168.0.0/16"},
+		{ip: Key{192, 169, 0, 1}},
+		{ip: Key{0, 0, 0, 0}},
+	} {
+		_, value, ok := tree.LongestPrefix(tc.ip)
+		assert.Equalf(t, tc.route != "", ok, "ip %v", tc.ip)
+		assert.Equalf(t, tc.route, value, "ip %v", tc.ip)
+	}
+}
+
+func TestTree_LongestPrefixProperty(t *testing.T) {
+	alphabet := []byte{0x00, 0x01, 0x7f, 0xff}
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		tree := NewArtTree()
+		ref := map[string]bool{}
+		for i := 0; i < 200; i++ {
+			key := randomBinaryKey(rng, alphabet)
+			tree.Insert(key, Value(key))
+			ref[string(key)] = true
+		}
+		for i := 0; i < 200; i++ {
+			query := randomBinaryKey(rng, alphabet)
+			var expected Key
+			found := false
+			for key := range ref {
+				if bytes.HasPrefix(query, []byte(key)) && (!found || len(key) > len(expected)) {
+					expected, found = Key(key), true
+				}
+			}
+			key, value, ok := tree.LongestPrefix(query)
+			require.Equalf(t, found, ok, "seed %d, query %q", seed, query)
+			if found {
+				require.Equalf(t, string(expected), string(key), "seed %d, query %q", seed, query)
+				require.Equal(t, string(expected), string(value))
+			}
+		}
+	}
+}
+
 type keyValueGenerator struct {
 	cur       int
 	generator func([]byte) []byte
@@ -607,3 +1011,229 @@ func BenchmarkWordsMapInsert(b *testing.B) {
 		}
 	}
 }
+
+func TestTree_Walk(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Walk(func(NodeInfo) bool {
+		t.Fatal("empty tree has no nodes")
+		return false
+	})
+
+	keys := []string{"a", "ab", "abc", "abd", "b", "this_is_a_long_prefix::1", "this_is_a_long_prefix::2"}
+	for i, key := range keys {
+		tree.Insert(Key(key), i)
+	}
+	var (
+		leaves []string
+		nodes  []NodeInfo
+	)
+	tree.Walk(func(info NodeInfo) bool {
+		if info.Kind == Leaf {
+			leaves = append(leaves, string(info.Key))
+		} else {
+			nodes = append(nodes, info)
+		}
+		return true
+	})
+	assert.Equal(t, keys, leaves)
+	require.Len(t, nodes, 4)
+	// root: {a, b, t}
+	assert.Equal(t, NodeInfo{Kind: Node4, Depth: 0, Prefix: []byte{}, Children: 3}, nodes[0])
+	// a: leaf "a", {b}
+	assert.Equal(t, NodeInfo{Kind: Node4, Depth: 1, Prefix: []byte{}, Children: 1}, nodes[1])
+	// ab: leaf "ab", {c, d}
+	assert.Equal(t, NodeInfo{Kind: Node4, Depth: 2, Prefix: []byte{}, Children: 2}, nodes[2])
+	// the long prefix is cut in the node
+	assert.Equal(t, NodeInfo{Kind: Node4, Depth: 1, Prefix: []byte("his_is_a_l"), PrefixLen: 22, Children: 2}, nodes[3])
+
+	// depth first
+	var depths []int
+	tree.Walk(func(info NodeInfo) bool {
+		depths = append(depths, info.Depth)
+		return true
+	})
+	assert.Equal(t, []int{0, 1, 2, 2, 3, 3, 3, 1, 1, 2, 2}, depths)
+
+	visited := 0
+	tree.Walk(func(info NodeInfo) bool {
+		visited++
+		return visited < 3
+	})
+	assert.Equal(t, 3, visited)
+
+	// the prefix belongs to the callback, writing it leaves the tree intact
+	tree.Walk(func(info NodeInfo) bool {
+		for i := range info.Prefix {
+			info.Prefix[i] = 'x'
+		}
+		return true
+	})
+	for i, key := range keys {
+		value, found := tree.Search(Key(key))
+		assert.True(t, found, key)
+		assert.Equal(t, i, value)
+	}
+}
+
+func TestTree_WalkNodeKinds(t *testing.T) {
+	for _, tc := range []struct {
+		children int
+		kind     Kind
+	}{
+		{children: 4, kind: Node4},
+		{children: 16, kind: Node16},
+		{children: 48, kind: Node48},
+		{children: 256, kind: Node256},
+	} {
+		tree := Tree[int]{}
+		// the children of node48 are stored out of order
+		for i := tc.children - 1; i >= 0; i-- {
+			tree.Insert(Key{byte(i)}, i)
+		}
+		var keys []Key
+		tree.Walk(func(info NodeInfo) bool {
+			if info.Depth == 0 {
+				assert.Equal(t, tc.kind, info.Kind)
+				assert.Equal(t, tc.children, info.Children)
+			} else {
+				keys = append(keys, info.Key)
+			}
+			return true
+		})
+		require.Len(t, keys, tc.children)
+		for i, key := range keys {
+			assert.Equal(t, Key{byte(i)}, key)
+		}
+	}
+}
+
+func TestTree_Compute(t *testing.T) {
+	increment := func(old int, exists bool) (int, Action) {
+		return old + 1, Store
+	}
+	tree := Tree[int]{}
+
+	// empty tree and the root leaf
+	value, ok := tree.Compute(Key("a"), increment)
+	assert.Equal(t, 1, value)
+	assert.True(t, ok)
+	value, ok = tree.Compute(Key("a"), increment)
+	assert.Equal(t, 2, value)
+	assert.True(t, ok)
+
+	for _, tc := range []struct {
+		desc   string
+		key    string
+		fn     func(int, bool) (int, Action)
+		value  int
+		ok     bool
+		exists bool
+	}{
+		{desc: "expand the root leaf", key: "ab", fn: increment, value: 1, ok: true, exists: true},
+		{desc: "leaf of a node", key: "a", fn: increment, value: 3, ok: true, exists: true},
+		{desc: "new child", key: "b", fn: increment, value: 1, ok: true, exists: true},
+		{desc: "expand a leaf child", key: "abc", fn: increment, value: 1, ok: true, exists: true},
+		{desc: "split the prefix", key: "this_is_a_long_prefix::1", fn: increment, value: 1, ok: true, exists: true},
+		{desc: "split the long prefix", key: "this_is_a_long_prefix::2", fn: increment, value: 1, ok: true, exists: true},
+		{desc: "split a long prefix", key: "this_is_a_short", fn: increment, value: 1, ok: true, exists: true},
+		{
+			desc: "keep an existing key", key: "ab", value: 1, ok: true, exists: true,
+			fn: func(old int, exists bool) (int, Action) {
+				return 100, Keep
+			},
+		},
+		{
+			desc: "keep a missing key", key: "abd",
+			fn: func(old int, exists bool) (int, Action) {
+				return 100, Keep
+			},
+		},
+		{
+			desc: "delete a missing key", key: "this_is_a",
+			fn: func(old int, exists bool) (int, Action) {
+				return 0, Delete
+			},
+		},
+		{
+			desc: "delete the leaf of a node", key: "ab",
+			fn: func(old int, exists bool) (int, Action) {
+				return 0, Delete
+			},
+		},
+		{
+			desc: "delete and collapse", key: "this_is_a_short",
+			fn: func(old int, exists bool) (int, Action) {
+				return 0, Delete
+			},
+		},
+	} {
+		expected, existed := tree.Search(Key(tc.key))
+		called := 0
+		value, ok := tree.Compute(Key(tc.key), func(old int, exists bool) (int, Action) {
+			called++
+			assert.Equal(t, existed, exists, tc.desc)
+			assert.Equal(t, expected, old, tc.desc)
+			return tc.fn(old, exists)
+		})
+		assert.Equal(t, 1, called, tc.desc)
+		assert.Equal(t, tc.value, value, tc.desc)
+		assert.Equal(t, tc.ok, ok, tc.desc)
+		got, found := tree.Search(Key(tc.key))
+		assert.Equal(t, tc.exists, found, tc.desc)
+		assert.Equal(t, tc.value, got, tc.desc)
+	}
+
+	expected := map[string]int{"a": 3, "abc": 1, "b": 1, "this_is_a_long_prefix::1": 1, "this_is_a_long_prefix::2": 1}
+	assert.Equal(t, len(expected), tree.Len())
+	for key, value := range expected {
+		got, found := tree.Search(Key(key))
+		assert.True(t, found, key)
+		assert.Equal(t, value, got, key)
+	}
+}
+
+func TestTree_ComputeProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	alphabet := []byte{0, 1, 2, 'a'}
+	tree := Tree[int]{}
+	expected := map[string]int{}
+	for i := 0; i < 20_000; i++ {
+		key := randomBinaryKey(rng, alphabet)
+		action := Action(rng.Intn(3))
+		old, exists := expected[string(key)]
+		value, ok := tree.Compute(key, func(got int, found bool) (int, Action) {
+			require.Equal(t, exists, found)
+			require.Equal(t, old, got)
+			return i, action
+		})
+		switch {
+		case action == Store:
+			expected[string(key)] = i
+			require.Equal(t, i, value)
+			require.True(t, ok)
+		case action == Delete:
+			delete(expected, string(key))
+			require.False(t, ok)
+		default:
+			require.Equal(t, exists, ok)
+			require.Equal(t, old, value)
+		}
+		// the other write paths are mixed in
+		if rng.Intn(4) == 0 {
+			key = randomBinaryKey(rng, alphabet)
+			if rng.Intn(2) == 0 {
+				tree.Insert(key, -i)
+				expected[string(key)] = -i
+			} else {
+				tree.Remove(key)
+				delete(expected, string(key))
+			}
+		}
+		require.Equal(t, len(expected), tree.Len())
+	}
+	for key, value := range expected {
+		got, found := tree.Search(Key(key))
+		require.True(t, found)
+		require.Equal(t, value, got)
+	}
+}
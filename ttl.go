@@ -0,0 +1,246 @@
+package art
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock tells the time to a TTLTree, it's replaced by a fake one in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// expiring is the value of a TTLTree, the zero deadline never expires.
+type expiring[T any] struct {
+	value    T
+	deadline time.Time
+}
+
+func (e expiring[T]) expired(now time.Time) bool {
+	return !e.deadline.IsZero() && !now.Before(e.deadline)
+}
+
+// TTLTree is a Tree whose keys may expire. The expired keys are hidden from the reads
+// right away, and removed by Reap, which is run periodically by StartReaper.
+// The zero value is an empty tree using the system clock.
+type TTLTree[T any] struct {
+	// Clock is the source of the time of the tree, the system clock if it's nil.
+	Clock Clock
+
+	tree Tree[expiring[T]]
+
+	// index holds the deadlines of the keys, it's ordered by deadline. entries holds
+	// the deadline of each key of index, which is updated in place when the key is
+	// inserted again. Both are updated while the key is locked in the tree, they
+	// always match the deadlines of the tree.
+	mu      sync.Mutex
+	index   deadlines
+	entries map[string]*deadline
+}
+
+type deadline struct {
+	at  time.Time
+	key Key
+	// index is the position of the deadline in the heap
+	index int
+}
+
+// deadlines is a min-heap of deadlines.
+type deadlines []*deadline
+
+func (d deadlines) Len() int           { return len(d) }
+func (d deadlines) Less(i, j int) bool { return d[i].at.Before(d[j].at) }
+func (d deadlines) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+	d[i].index, d[j].index = i, j
+}
+func (d *deadlines) Push(x any) {
+	x.(*deadline).index = len(*d)
+	*d = append(*d, x.(*deadline))
+}
+func (d *deadlines) Pop() any {
+	old := *d
+	x := old[len(old)-1]
+	old[len(old)-1] = nil
+	*d = old[:len(old)-1]
+	return x
+}
+
+// setDeadline sets the deadline of key in the index, the zero deadline removes it.
+// The key must be locked in the tree.
+func (t *TTLTree[T]) setDeadline(key Key, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.entries[string(key)]
+	switch {
+	case ok && at.IsZero():
+		heap.Remove(&t.index, d.index)
+		delete(t.entries, string(key))
+	case ok:
+		d.at = at
+		heap.Fix(&t.index, d.index)
+	case !at.IsZero():
+		if t.entries == nil {
+			t.entries = map[string]*deadline{}
+		}
+		d = &deadline{at: at, key: key}
+		heap.Push(&t.index, d)
+		t.entries[string(key)] = d
+	}
+}
+
+func (t *TTLTree[T]) now() time.Time {
+	if t.Clock == nil {
+		return systemClock{}.Now()
+	}
+	return t.Clock.Now()
+}
+
+// Insert stores value for key with no expiry, updated is true if the key was present and not expired.
+func (t *TTLTree[T]) Insert(key Key, value T) (updated bool) {
+	return t.insert(key, expiring[T]{value: value})
+}
+
+// InsertWithTTL stores value for key until ttl elapses, updated is true if the key was present and not expired.
+func (t *TTLTree[T]) InsertWithTTL(key Key, value T, ttl time.Duration) (updated bool) {
+	return t.insert(key, expiring[T]{value: value, deadline: t.now().Add(ttl)})
+}
+
+func (t *TTLTree[T]) insert(key Key, e expiring[T]) (updated bool) {
+	now := t.now()
+	t.tree.Compute(key, func(old expiring[T], exists bool) (expiring[T], Action) {
+		updated = exists && !old.expired(now)
+		if !e.deadline.IsZero() || exists && !old.deadline.IsZero() {
+			t.setDeadline(key, e.deadline)
+		}
+		return e, Store
+	})
+	return updated
+}
+
+// Search returns the value of key, unless it's expired.
+func (t *TTLTree[T]) Search(key Key) (value T, found bool) {
+	e, found := t.tree.Search(key)
+	if !found || e.expired(t.now()) {
+		return value, false
+	}
+	return e.value, true
+}
+
+// Remove removes key and returns its value, deleted is true if the key was present and not expired.
+// An expired key is removed as well.
+func (t *TTLTree[T]) Remove(key Key) (deleted bool, value T) {
+	var e expiring[T]
+	t.tree.Compute(key, func(old expiring[T], exists bool) (expiring[T], Action) {
+		if !exists {
+			return old, Keep
+		}
+		if !old.deadline.IsZero() {
+			t.setDeadline(key, time.Time{})
+		}
+		deleted, e = true, old
+		return old, Delete
+	})
+	if !deleted || e.expired(t.now()) {
+		return false, value
+	}
+	return true, e.value
+}
+
+// Len returns the number of keys in the tree, the expired keys are counted until they are reaped.
+func (t *TTLTree[T]) Len() int {
+	return t.tree.Len()
+}
+
+// Iterator returns an iterator in range (start, end] skipping the expired keys, see Tree.Iterator.
+func (t *TTLTree[T]) Iterator(start, end []byte) *ttlIterator[T] {
+	return &ttlIterator[T]{iterator: t.tree.Iterator(start, end), now: t.now}
+}
+
+// Reap removes the keys whose deadline has passed and returns the number of removed keys.
+// A key is removed like Remove does, only if its deadline still has passed when it's locked.
+func (t *TTLTree[T]) Reap() (removed int) {
+	now := t.now()
+	for {
+		t.mu.Lock()
+		if len(t.index) == 0 || t.index[0].at.After(now) {
+			t.mu.Unlock()
+			return removed
+		}
+		key := t.index[0].key
+		t.mu.Unlock()
+
+		// the deadline may have changed in between, it's read again with the key locked
+		t.tree.Compute(key, func(old expiring[T], exists bool) (expiring[T], Action) {
+			if !exists || !old.expired(now) {
+				return old, Keep
+			}
+			t.setDeadline(key, time.Time{})
+			removed++
+			return old, Delete
+		})
+	}
+}
+
+// StartReaper runs Reap every interval in the background, until stop is called.
+func (t *TTLTree[T]) StartReaper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				t.Reap()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+type ttlIterator[T any] struct {
+	iterator *iterator[expiring[T]]
+	now      func() time.Time
+}
+
+// Reverse turns the iteration into the reverse order, see Tree.Iterator.
+func (i *ttlIterator[T]) Reverse() *ttlIterator[T] {
+	i.iterator.Reverse()
+	return i
+}
+
+// Next moves to the next key of the range which isn't expired.
+func (i *ttlIterator[T]) Next() bool {
+	now := i.now()
+	for i.iterator.Next() {
+		if !i.iterator.Value().expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *ttlIterator[T]) Key() Key {
+	return i.iterator.Key()
+}
+
+func (i *ttlIterator[T]) Value() T {
+	return i.iterator.Value().value
+}
@@ -0,0 +1,144 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTTLTree(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tree := TTLTree[int]{Clock: clock}
+	assert.False(t, tree.InsertWithTTL(Key("a"), 1, time.Second))
+	assert.False(t, tree.InsertWithTTL(Key("b"), 2, 2*time.Second))
+	assert.False(t, tree.Insert(Key("c"), 3))
+
+	value, found := tree.Search(Key("a"))
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	clock.Advance(time.Second)
+	_, found = tree.Search(Key("a"))
+	assert.False(t, found)
+	var keys []string
+	for iter := tree.Iterator(nil, nil); iter.Next(); {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"b", "c"}, keys)
+	keys = keys[:0]
+	for iter := tree.Iterator(nil, nil).Reverse(); iter.Next(); {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"c", "b"}, keys)
+	// the expired key is still counted until it's reaped
+	assert.Equal(t, 3, tree.Len())
+
+	// "b" gets a new deadline, the old one is stale
+	assert.True(t, tree.InsertWithTTL(Key("b"), 4, 10*time.Second))
+	clock.Advance(time.Second)
+	assert.Equal(t, 1, tree.Reap())
+	assert.Equal(t, 2, tree.Len())
+	value, found = tree.Search(Key("b"))
+	assert.True(t, found)
+	assert.Equal(t, 4, value)
+
+	// an expired key is replaced like a missing one
+	clock.Advance(10 * time.Second)
+	assert.False(t, tree.InsertWithTTL(Key("b"), 5, time.Second))
+	deleted, value := tree.Remove(Key("b"))
+	assert.True(t, deleted)
+	assert.Equal(t, 5, value)
+	assert.Equal(t, 0, tree.Reap())
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestTTLTree_ReapShrinks(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tree := TTLTree[int]{Clock: clock}
+	tree.Insert(Key("k"), 0)
+	for i := 0; i < 48; i++ {
+		tree.InsertWithTTL(Key{'k', byte(i)}, i, time.Duration(i+1)*time.Second)
+	}
+	kinds := func() (kinds []Kind) {
+		tree.tree.Walk(func(info NodeInfo) bool {
+			if info.Kind != Leaf {
+				kinds = append(kinds, info.Kind)
+			}
+			return true
+		})
+		return kinds
+	}
+	require.Equal(t, []Kind{Node48}, kinds())
+
+	clock.Advance(45 * time.Second)
+	assert.Equal(t, 45, tree.Reap())
+	assert.Equal(t, 4, tree.Len())
+	assert.Equal(t, []Kind{Node4}, kinds())
+	clock.Advance(time.Hour)
+	assert.Equal(t, 3, tree.Reap())
+	assert.Equal(t, 1, tree.Len())
+	assert.Empty(t, kinds())
+}
+
+func TestTTLTree_RefreshInPlace(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tree := TTLTree[int]{Clock: clock}
+	// refreshing a key moves its deadline instead of adding one
+	for i := 0; i < 100; i++ {
+		tree.InsertWithTTL(Key("a"), i, time.Duration(100-i)*time.Second)
+		tree.InsertWithTTL(Key("b"), i, time.Minute)
+	}
+	assert.Len(t, tree.index, 2)
+	assert.Len(t, tree.entries, 2)
+	assert.Equal(t, "a", string(tree.index[0].key))
+
+	// a key without expiry and a removed one have no deadline
+	tree.Insert(Key("a"), 0)
+	assert.Len(t, tree.index, 1)
+	tree.Remove(Key("b"))
+	assert.Empty(t, tree.index)
+	assert.Empty(t, tree.entries)
+
+	tree.InsertWithTTL(Key("c"), 1, time.Second)
+	clock.Advance(time.Hour)
+	assert.Equal(t, 1, tree.Reap())
+	assert.Empty(t, tree.index)
+	assert.Empty(t, tree.entries)
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestTTLTree_Reaper(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tree := TTLTree[int]{Clock: clock}
+	for i := 0; i < 100; i++ {
+		tree.InsertWithTTL(Key(fmt.Sprintf("session::%d", i)), i, time.Minute)
+	}
+	stop := tree.StartReaper(time.Millisecond)
+	defer stop()
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		return tree.Len() == 0
+	}, 5*time.Second, time.Millisecond)
+	stop()
+}
@@ -0,0 +1,567 @@
+package art
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"sync/atomic"
+)
+
+// Tx is a transaction of Tree.Txn. The reads see the tree as it is when they happen and the
+// writes of the transaction, the writes are buffered until the transaction commits.
+// A Tx must not be used after its function returns, nor by several goroutines.
+type Tx[T any] struct {
+	tree   *Tree[T]
+	reads  []txRead
+	writes map[string]txWrite[T]
+}
+
+// txRead is the version of a node read, the one holding the leaf of a key read or its absence,
+// or a node of a range read.
+type txRead struct {
+	lock    *olock
+	version uint64
+}
+
+type txWrite[T any] struct {
+	value  T
+	delete bool
+}
+
+// Txn runs fn in a transaction, the writes of fn are applied at once if it returns nil, and discarded
+// if it returns an error, which is returned by Txn.
+//
+// The transactions are optimistic: the reads record the versions of the nodes they went through, the
+// commit checks that none of them changed, then applies the writes in key order. Otherwise fn is run again
+// in a new transaction, so it may run several times and shouldn't have effects outside of tx. A run which
+// conflicts may have read an inconsistent state, only the reads of the committed run are consistent.
+// The transactions are serializable, among themselves and with the other writes of the tree.
+//
+// The commit locks only the nodes changed by the writes, a transaction without writes locks nothing.
+// A snapshot sees a transaction either entirely or not at all, the reads outside of a transaction
+// may see it half applied, like ApplyBatch.
+func (t *Tree[T]) Txn(fn func(tx *Tx[T]) error) error {
+	for {
+		tx := &Tx[T]{tree: t}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if tx.commit() {
+			return nil
+		}
+	}
+}
+
+// Get returns the value of key, the one written by the transaction if any.
+func (tx *Tx[T]) Get(key Key) (value T, found bool) {
+	if w, ok := tx.writes[string(key)]; ok {
+		return w.value, !w.delete
+	}
+	l := tx.read(key)
+	if l == nil {
+		return value, false
+	}
+	return l.value, true
+}
+
+func (tx *Tx[T]) read(key Key) *leaf[T] {
+	l, lock, version := tx.tree.locate(key)
+	tx.record(lock, version)
+	return l
+}
+
+func (tx *Tx[T]) record(lock *olock, version uint64) {
+	tx.reads = append(tx.reads, txRead{lock: lock, version: version})
+}
+
+// Put stores value for key when the transaction commits.
+func (tx *Tx[T]) Put(key Key, value T) {
+	tx.write(key, txWrite[T]{value: value})
+}
+
+// Delete removes key when the transaction commits.
+func (tx *Tx[T]) Delete(key Key) {
+	tx.write(key, txWrite[T]{delete: true})
+}
+
+func (tx *Tx[T]) write(key Key, w txWrite[T]) {
+	if tx.writes == nil {
+		tx.writes = map[string]txWrite[T]{}
+	}
+	tx.writes[string(key)] = w
+}
+
+// Range calls fn with the keys in r and their values in order, including the writes of the transaction,
+// until fn returns false. The nodes read by the iteration are validated by the commit, a key added to
+// the part of the range read, removed from it or changed by another write conflicts with the transaction.
+func (tx *Tx[T]) Range(r Range, fn func(key Key, value T) bool) {
+	var written []txEntry[T]
+	for key, w := range tx.writes {
+		if contains(r, Key(key)) {
+			written = append(written, txEntry[T]{key: Key(key), value: w.value, delete: w.delete})
+		}
+	}
+	sort.Slice(written, func(i, j int) bool {
+		return bytes.Compare(written[i].key, written[j].key) < 0
+	})
+
+	iter := tx.tree.RangeIterator(r)
+	iter.cursor.read = tx.record
+	more := iter.Next()
+	for more || len(written) > 0 {
+		var e txEntry[T]
+		switch {
+		case len(written) == 0 || more && bytes.Compare(iter.Key(), written[0].key) < 0:
+			e = txEntry[T]{key: iter.Key(), value: iter.Value()}
+			more = iter.Next()
+		default:
+			if more && bytes.Equal(iter.Key(), written[0].key) {
+				more = iter.Next()
+			}
+			e, written = written[0], written[1:]
+		}
+		if !e.delete && !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+type txEntry[T any] struct {
+	key    Key
+	value  T
+	delete bool
+}
+
+// contains reports whether key is in r.
+func contains(r Range, key Key) bool {
+	if r.Lower != nil {
+		cmp := bytes.Compare(key, r.Lower.Key)
+		if cmp < 0 || cmp == 0 && !r.Lower.Inclusive {
+			return false
+		}
+	}
+	if r.Upper != nil {
+		cmp := bytes.Compare(key, r.Upper.Key)
+		if cmp > 0 || cmp == 0 && !r.Upper.Inclusive {
+			return false
+		}
+	}
+	return true
+}
+
+// commit validates the reads and applies the writes, it returns false if a read is outdated.
+// The reads are validated before anything is locked, and again once the writes are applied.
+func (tx *Tx[T]) commit() bool {
+	if !tx.valid(nil) {
+		return false
+	}
+	if len(tx.writes) == 0 {
+		// every read was current when the first one was validated
+		return true
+	}
+	t := tx.tree
+	keys := make([]string, 0, len(tx.writes))
+	for key := range tx.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for {
+		c := &txCommit[T]{tree: t, held: map[*olock]uint64{}, unlinked: map[*olock]bool{}}
+		applied := true
+		for _, key := range keys {
+			if !c.apply(Key(key), tx.writes[key]) {
+				applied = false
+				break
+			}
+		}
+		if applied && !tx.valid(c.held) {
+			c.undo()
+			return false
+		}
+		if applied {
+			// a snapshot taken during the commit waits for the nodes held, it sees the whole
+			// commit unless a node changed has been frozen before it was locked
+			if !c.frozen() {
+				c.release()
+				return true
+			}
+		}
+		c.undo()
+		if c.thaw {
+			t.compute(c.thawKey, func(*leaf[T]) (value T, _ Action) {
+				return value, Keep
+			})
+		} else {
+			runtime.Gosched()
+		}
+		if !tx.valid(nil) {
+			return false
+		}
+	}
+}
+
+// valid reports whether the reads are still current. held are the locks taken by the
+// commit, with the versions their nodes had before, the commit doesn't outdate a read.
+func (tx *Tx[T]) valid(held map[*olock]uint64) bool {
+	for _, read := range tx.reads {
+		if version, ok := held[read.lock]; ok {
+			if version != read.version {
+				return false
+			}
+		} else if read.lock.Check(read.version) {
+			return false
+		}
+	}
+	return true
+}
+
+// txCommit applies the writes of a transaction in key order. The nodes it changes stay write
+// locked until the end of the commit, their content is saved before, so that the writes can be
+// undone if a read is outdated. It never waits for a node while it holds others, a node locked
+// by another writer aborts the commit instead.
+type txCommit[T any] struct {
+	tree *Tree[T]
+	// held are the locks taken, with the version of their node before
+	held map[*olock]uint64
+	// saved are copies of the inner nodes locked, root is the root of the tree if it's locked
+	saved []txSaved[T]
+	root  node[T]
+	// unlinked are the locks of the nodes removed from the tree, they are released as obsolete
+	unlinked map[*olock]bool
+	events   []txEvent[T]
+	delta    int
+	// thaw is set if the commit aborted on a node shared with a snapshot,
+	// the path of thawKey is copied before the commit starts again
+	thaw    bool
+	thawKey Key
+}
+
+// txSaved is a copy of a node locked for the write of key.
+type txSaved[T any] struct {
+	node, copy *inner[T]
+	key        Key
+}
+
+// txEvent is the change of a watched key, sent to the watchers once the commit succeeds.
+type txEvent[T any] struct {
+	key    Key
+	old    *leaf[T]
+	value  T
+	action Action
+}
+
+// lockOf returns the lock of n, the nil node is the root of the tree.
+func (c *txCommit[T]) lockOf(n *inner[T]) *olock {
+	if n == nil {
+		return &c.tree.lock
+	}
+	return &n.lock
+}
+
+// rlock reads the version of n, ok is false if n is write locked by another writer
+// while the commit holds nodes.
+func (c *txCommit[T]) rlock(n *inner[T]) (version uint64, obsolete, ok bool) {
+	l := c.lockOf(n)
+	if _, held := c.held[l]; held {
+		return 0, false, true
+	}
+	if len(c.held) == 0 {
+		version, obsolete = l.RLock()
+		return version, obsolete, true
+	}
+	return l.TryRLock()
+}
+
+// changed reports whether n has been changed since version, the nodes held don't change.
+func (c *txCommit[T]) changed(n *inner[T], version uint64) bool {
+	l := c.lockOf(n)
+	if _, held := c.held[l]; held {
+		return false
+	}
+	return l.RUnlock(version, nil)
+}
+
+// check is changed without releasing the read of n.
+func (c *txCommit[T]) check(n *inner[T], version uint64) bool {
+	if _, held := c.held[&n.lock]; held {
+		return false
+	}
+	return n.lock.Check(version)
+}
+
+// hold write locks l read at version, it returns false if the node has been changed.
+func (c *txCommit[T]) hold(l *olock, version uint64) bool {
+	if _, held := c.held[l]; held {
+		return true
+	}
+	if l.Upgrade(version, nil) {
+		return false
+	}
+	c.held[l] = version
+	return true
+}
+
+// lock holds n for the write of key and saves its content, it returns false if n has been changed.
+func (c *txCommit[T]) lock(n *inner[T], version uint64, key Key) bool {
+	l := c.lockOf(n)
+	if _, held := c.held[l]; held {
+		return true
+	}
+	if !c.hold(l, version) {
+		return false
+	}
+	if n == nil {
+		c.root = c.tree.root
+	} else {
+		c.saved = append(c.saved, txSaved[T]{node: n, copy: n.clone(n.epoch), key: key})
+	}
+	return true
+}
+
+// frozen reports whether a node changed has been frozen by a snapshot.
+func (c *txCommit[T]) frozen() bool {
+	for _, s := range c.saved {
+		if s.node.epoch.isFrozen() {
+			c.thaw, c.thawKey = true, s.key
+			return true
+		}
+	}
+	return false
+}
+
+// event returns what fn of compute returns for w, and records the event of the key if it's watched.
+func (c *txCommit[T]) event(key Key, old *leaf[T], w txWrite[T]) (T, Action) {
+	action := Store
+	if w.delete {
+		action = Delete
+	}
+	if c.tree.watched(key) {
+		c.events = append(c.events, txEvent[T]{key: key, old: old, value: w.value, action: action})
+	}
+	return w.value, action
+}
+
+// apply applies the write of key like compute, with the nodes changed kept locked.
+// It returns false if the commit has to abort.
+func (c *txCommit[T]) apply(key Key, w txWrite[T]) bool {
+	t := c.tree
+restart:
+	for {
+		version, _, ok := c.rlock(nil)
+		if !ok {
+			return false
+		}
+		root, isInner := t.root.(*inner[T])
+		if !isInner {
+			// the tree is empty or a single leaf
+			if !c.lock(nil, version, key) {
+				continue
+			}
+			var old *leaf[T]
+			if l, ok := t.root.(*leaf[T]); ok && l.cmp(key) {
+				old = l
+			}
+			value, action := c.event(key, old, w)
+			switch {
+			case action == Store && (t.root == nil || old != nil):
+				t.root = &leaf[T]{key: key, value: value}
+				if old == nil {
+					c.delta++
+				}
+			case action == Store:
+				expanded, _, _ := t.root.insert(&leaf[T]{key: key, value: value}, 0, &t.lock, version)
+				expanded.(*inner[T]).epoch = t.current()
+				t.root = expanded
+				c.delta++
+			case action == Delete && old != nil:
+				t.root = nil
+				c.delta--
+			}
+			return true
+		}
+		if root.epoch != t.epoch {
+			c.changed(nil, version)
+			c.thaw, c.thawKey = true, key
+			return false
+		}
+
+		n, depth := root, 0
+		var parent *inner[T]
+		parentIdx, parentVersion := 0, version
+		for {
+			nodeVersion, obsolete, ok := c.rlock(n)
+			if !ok {
+				c.changed(parent, parentVersion)
+				return false
+			}
+			if obsolete {
+				continue restart
+			}
+			mismatch := n.prefixMismatch(key, depth)
+			nextDepth := depth + n.prefixLen
+			if mismatch >= n.prefixLen && len(key) > nextDepth {
+				idx, next := n.node.child(key[nextDepth])
+				if child, ok := next.(*inner[T]); ok {
+					if c.changed(parent, parentVersion) || c.check(n, nodeVersion) {
+						continue restart
+					}
+					if child.epoch != n.epoch {
+						c.thaw, c.thawKey = true, key
+						return false
+					}
+					parent, parentIdx, parentVersion = n, idx, nodeVersion
+					n, depth = child, nextDepth+1
+					continue
+				}
+			}
+
+			// n holds the leaf of the key or is where it's missing, removing
+			// the leaf collapses n if it's the last but one entry of n
+			collapse := false
+			if w.delete && mismatch >= n.prefixLen {
+				if len(key) == nextDepth {
+					collapse = n.leaf != nil && n.node.size() == 1
+				} else if _, next := n.node.child(key[nextDepth]); next != nil {
+					l, isLeaf := next.(*leaf[T])
+					collapse = isLeaf && l.cmp(key) && n.entries() == 2
+				}
+			}
+			if !c.lock(n, nodeVersion, key) {
+				c.changed(parent, parentVersion)
+				continue restart
+			}
+			if collapse && !c.lock(parent, parentVersion, key) || !collapse && c.changed(parent, parentVersion) {
+				continue restart
+			}
+			return c.applyNode(n, key, depth, mismatch, parent, parentIdx, w)
+		}
+	}
+}
+
+// applyNode applies the write of key to the locked node n, found at depth, whose prefix
+// mismatches key at mismatch, like compute. The parent is locked if the write collapses n.
+func (c *txCommit[T]) applyNode(n *inner[T], key Key, depth, mismatch int, parent *inner[T], parentIdx int, w txWrite[T]) bool {
+	delta, ok := n.apply(key, depth, mismatch, func(old *leaf[T]) (T, Action) {
+		return c.event(key, old, w)
+	}, func() bool {
+		return c.collapse(n, key, parent, parentIdx)
+	})
+	c.delta += delta
+	return ok
+}
+
+// collapse replaces the locked node n in its locked parent by the only entry left in n once key
+// is removed, like inner.collapse. The child which gets the prefix of n is locked too, the commit
+// aborts if it can't be.
+func (c *txCommit[T]) collapse(n *inner[T], key Key, parent *inner[T], parentIdx int) bool {
+	entry, ok := n.remainder(func(child *inner[T], frozen bool) bool {
+		version, _, ok := c.rlock(child)
+		if !ok {
+			return false
+		}
+		if frozen {
+			// the child is replaced by its copy
+			if !c.hold(&child.lock, version) {
+				return false
+			}
+			c.unlinked[&child.lock] = true
+			return true
+		}
+		return c.lock(child, version, key)
+	})
+	if !ok {
+		return false
+	}
+	if parent == nil {
+		c.tree.root = entry
+	} else {
+		parent.node.replace(parentIdx, entry)
+	}
+	c.unlinked[&n.lock] = true
+	return true
+}
+
+// release notifies the watchers of the writes and unlocks the nodes.
+func (c *txCommit[T]) release() {
+	for _, e := range c.events {
+		c.tree.notify(e.key, e.old, e.value, e.action)
+	}
+	for l := range c.held {
+		if c.unlinked[l] {
+			l.UnlockObsolete()
+		} else {
+			l.Unlock()
+		}
+	}
+	atomic.AddInt64(&c.tree.size, int64(c.delta))
+}
+
+// undo restores the nodes changed by the commit and unlocks them.
+func (c *txCommit[T]) undo() {
+	for _, s := range c.saved {
+		s.node.prefix, s.node.prefixLen = s.copy.prefix, s.copy.prefixLen
+		s.node.leaf, s.node.node = s.copy.leaf, s.copy.node
+	}
+	if _, ok := c.held[&c.tree.lock]; ok {
+		c.tree.root = c.root
+	}
+	for l := range c.held {
+		l.Unlock()
+	}
+}
+
+// locate returns the leaf of key, if any, with the lock of the node which holds the leaf, where
+// the key is missing or where it diverges from the whole prefix of the node, and the version of
+// the node read. Any write of the key changes it.
+func (t *Tree[T]) locate(key Key) (l *leaf[T], lock *olock, version uint64) {
+restart:
+	for {
+		lock = &t.lock
+		version, _ = t.lock.RLock()
+		n := t.root
+		depth := 0
+		for {
+			switch current := n.(type) {
+			case nil:
+				if lock.RUnlock(version, nil) {
+					continue restart
+				}
+				return nil, lock, version
+			case *leaf[T]:
+				if lock.RUnlock(version, nil) {
+					continue restart
+				}
+				if current.cmp(key) {
+					return current, lock, version
+				}
+				return nil, lock, version
+			case *inner[T]:
+				nodeVersion, obsolete := current.lock.RLock()
+				if obsolete || lock.RUnlock(version, nil) {
+					continue restart
+				}
+				lock, version = &current.lock, nodeVersion
+				if current.prefixMismatch(key, depth) < current.prefixLen {
+					if lock.RUnlock(version, nil) {
+						continue restart
+					}
+					return nil, lock, version
+				}
+				nextDepth := depth + current.prefixLen
+				if len(key) <= nextDepth {
+					// the key terminates at this node
+					l = current.leaf
+					if lock.RUnlock(version, nil) {
+						continue restart
+					}
+					if l != nil && l.cmp(key) {
+						return l, lock, version
+					}
+					return nil, lock, version
+				}
+				_, n = current.node.child(key[nextDepth])
+				depth = nextDepth + 1
+			}
+		}
+	}
+}
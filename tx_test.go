@@ -0,0 +1,362 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree_Txn(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a"), 1)
+	tree.Insert(Key("b"), 2)
+
+	err := tree.Txn(func(tx *Tx[int]) error {
+		a, found := tx.Get(Key("a"))
+		assert.True(t, found)
+		tx.Put(Key("a"), a+10)
+		tx.Put(Key("c"), 3)
+		tx.Delete(Key("b"))
+
+		// the transaction reads its own writes, the tree doesn't see them yet
+		a, _ = tx.Get(Key("a"))
+		assert.Equal(t, 11, a)
+		_, found = tx.Get(Key("b"))
+		assert.False(t, found)
+		value, _ := tree.Search(Key("a"))
+		assert.Equal(t, 1, value)
+		_, found = tree.Search(Key("c"))
+		assert.False(t, found)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, tree.Len())
+	value, _ := tree.Search(Key("a"))
+	assert.Equal(t, 11, value)
+	value, _ = tree.Search(Key("c"))
+	assert.Equal(t, 3, value)
+	_, found := tree.Search(Key("b"))
+	assert.False(t, found)
+}
+
+func TestTree_TxnAbort(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a"), 1)
+
+	abort := errors.New("abort")
+	err := tree.Txn(func(tx *Tx[int]) error {
+		tx.Put(Key("a"), 2)
+		tx.Put(Key("b"), 3)
+		return abort
+	})
+	assert.ErrorIs(t, err, abort)
+	assert.Equal(t, 1, tree.Len())
+	value, _ := tree.Search(Key("a"))
+	assert.Equal(t, 1, value)
+}
+
+func TestTree_TxnRange(t *testing.T) {
+	tree := Tree[int]{}
+	for i, key := range []string{"a", "b", "ba", "c", "d"} {
+		tree.Insert(Key(key), i)
+	}
+
+	var got []string
+	err := tree.Txn(func(tx *Tx[int]) error {
+		got = got[:0]
+		tx.Put(Key("bb"), 10)
+		tx.Put(Key("c"), 11)
+		tx.Delete(Key("ba"))
+		tx.Put(Key("e"), 12)
+		tx.Range(Range{Lower: Excluded(Key("a")), Upper: Included(Key("d"))}, func(key Key, value int) bool {
+			got = append(got, fmt.Sprintf("%s=%d", key, value))
+			return true
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b=1", "bb=10", "c=11", "d=4"}, got)
+
+	got = got[:0]
+	_ = tree.Txn(func(tx *Tx[int]) error {
+		tx.Range(Range{}, func(key Key, value int) bool {
+			got = append(got, fmt.Sprintf("%s=%d", key, value))
+			return len(got) < 2
+		})
+		return nil
+	})
+	assert.Equal(t, []string{"a=0", "b=1"}, got)
+}
+
+func TestTree_TxnConflict(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a"), 1)
+
+	runs := 0
+	err := tree.Txn(func(tx *Tx[int]) error {
+		runs++
+		a, _ := tx.Get(Key("a"))
+		if runs == 1 {
+			// a write between the read and the commit runs the transaction again
+			tree.Insert(Key("a"), 5)
+		}
+		tx.Put(Key("b"), a)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	value, _ := tree.Search(Key("b"))
+	assert.Equal(t, 5, value)
+
+	// a key added to a range read conflicts as well
+	runs = 0
+	err = tree.Txn(func(tx *Tx[int]) error {
+		runs++
+		count := 0
+		tx.Range(Range{}, func(Key, int) bool {
+			count++
+			return true
+		})
+		if runs == 1 {
+			tree.Insert(Key("c"), 0)
+		}
+		tx.Put(Key("count"), count)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	value, _ = tree.Search(Key("count"))
+	assert.Equal(t, 3, value)
+
+	// a missing key too
+	runs = 0
+	err = tree.Txn(func(tx *Tx[int]) error {
+		runs++
+		_, found := tx.Get(Key("d"))
+		if runs == 1 {
+			assert.False(t, found)
+			tree.Insert(Key("d"), 0)
+		}
+		tx.Put(Key("found"), map[bool]int{false: 0, true: 1}[found])
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	value, _ = tree.Search(Key("found"))
+	assert.Equal(t, 1, value)
+}
+
+func TestTree_TxnConflictLongPrefix(t *testing.T) {
+	// the key read diverges from the prefix of the root after the bytes stored in the node,
+	// inserting it splits the root and has to conflict with the read
+	tree := Tree[int]{}
+	for _, key := range []string{"aaaaaaaaaaaaX1p", "aaaaaaaaaaaaX1q", "aaaaaaaaaaaaX2"} {
+		tree.Insert(Key(key), 0)
+	}
+	key := Key("aaaaaaaaaaZaX1r")
+
+	runs := 0
+	err := tree.Txn(func(tx *Tx[int]) error {
+		runs++
+		_, found := tx.Get(key)
+		if runs == 1 {
+			assert.False(t, found)
+			tree.Insert(key, 1)
+		}
+		tx.Put(Key("found"), map[bool]int{false: 0, true: 1}[found])
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	value, _ := tree.Search(Key("found"))
+	assert.Equal(t, 1, value)
+}
+
+func account(i int) Key {
+	return Key(fmt.Sprintf("account/%04d", i))
+}
+
+func TestTree_TxnBankTransfers(t *testing.T) {
+	const (
+		accounts  = 50
+		balance   = 1000
+		workers   = 8
+		transfers = 2000
+	)
+	tree := Tree[int]{}
+	for i := 0; i < accounts; i++ {
+		tree.Insert(account(i), balance)
+	}
+	accountsRange := Range{Lower: Included(Key("account/")), Upper: Excluded(Key("account0"))}
+
+	var wg sync.WaitGroup
+	var done int32
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < transfers; i++ {
+				from, to := account(rng.Intn(accounts)), account(rng.Intn(accounts))
+				amount := rng.Intn(100)
+				err := tree.Txn(func(tx *Tx[int]) error {
+					a, _ := tx.Get(from)
+					if a < amount {
+						return nil
+					}
+					tx.Put(from, a-amount)
+					b, _ := tx.Get(to)
+					tx.Put(to, b+amount)
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}(int64(w))
+	}
+	// the total seen by a transaction is always the same
+	var audits sync.WaitGroup
+	for a := 0; a < 2; a++ {
+		audits.Add(1)
+		go func() {
+			defer audits.Done()
+			for atomic.LoadInt32(&done) == 0 {
+				total, count := 0, 0
+				err := tree.Txn(func(tx *Tx[int]) error {
+					total, count = 0, 0
+					tx.Range(accountsRange, func(_ Key, value int) bool {
+						total += value
+						count++
+						return true
+					})
+					return nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, accounts, count)
+				assert.Equal(t, accounts*balance, total)
+			}
+		}()
+	}
+	wg.Wait()
+	atomic.StoreInt32(&done, 1)
+	audits.Wait()
+
+	total := 0
+	for iter := tree.RangeIterator(accountsRange); iter.Next(); {
+		assert.GreaterOrEqual(t, iter.Value(), 0)
+		total += iter.Value()
+	}
+	assert.Equal(t, accounts*balance, total)
+	assert.Equal(t, accounts, tree.Len())
+}
+
+func TestTree_TxnMoves(t *testing.T) {
+	// transactions move keys between slots, which splits and collapses the nodes they lock,
+	// while plain writes change the same nodes and snapshots copy them
+	const (
+		items   = 64
+		slots   = 256
+		workers = 8
+		moves   = 2000
+	)
+	slot := func(i int) Key {
+		return Key(fmt.Sprintf("slot/%x", i))
+	}
+	tree := Tree[int]{}
+	for i := 0; i < items; i++ {
+		tree.Insert(slot(i*slots/items), i)
+	}
+	slotsRange := Range{Lower: Included(Key("slot/")), Upper: Excluded(Key("slot0"))}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < moves; i++ {
+				from, to := slot(rng.Intn(slots)), slot(rng.Intn(slots))
+				switch i % 8 {
+				case 0:
+					tree.Insert(append(to, 'x'), i)
+				case 1:
+					tree.Remove(append(from, 'x'))
+				case 2:
+					tree.Snapshot()
+				case 3:
+					// a range read sees every item once
+					count := 0
+					_ = tree.Txn(func(tx *Tx[int]) error {
+						count = 0
+						tx.Range(slotsRange, func(key Key, _ int) bool {
+							if key[len(key)-1] != 'x' {
+								count++
+							}
+							return true
+						})
+						return nil
+					})
+					assert.Equal(t, items, count)
+				}
+				_ = tree.Txn(func(tx *Tx[int]) error {
+					item, found := tx.Get(from)
+					if _, taken := tx.Get(to); !found || taken {
+						return nil
+					}
+					tx.Delete(from)
+					tx.Put(to, item)
+					return nil
+				})
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+
+	seen, count := map[int]bool{}, 0
+	for iter := tree.RangeIterator(slotsRange); iter.Next(); count++ {
+		if key := iter.Key(); key[len(key)-1] != 'x' {
+			assert.False(t, seen[iter.Value()])
+			seen[iter.Value()] = true
+		}
+	}
+	assert.Len(t, seen, items)
+	assert.Equal(t, count, tree.Len())
+}
+
+func TestTree_TxnCounter(t *testing.T) {
+	// concurrent increments by transactions and by Compute are never lost
+	const (
+		workers    = 8
+		increments = 1000
+	)
+	tree := Tree[int]{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if w%2 == 0 {
+					tree.Compute(Key("counter"), func(old int, _ bool) (int, Action) {
+						return old + 1, Store
+					})
+					continue
+				}
+				_ = tree.Txn(func(tx *Tx[int]) error {
+					value, _ := tx.Get(Key("counter"))
+					tx.Put(Key("counter"), value+1)
+					return nil
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+	value, _ := tree.Search(Key("counter"))
+	assert.Equal(t, workers*increments, value)
+}
@@ -0,0 +1,181 @@
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The log is a sequence of segment files named by their sequence number,
+// a frame of the log is
+//
+//	crc32 uint32 | length uint32 | payload
+//
+// where the checksum is the CRC-32C of the length and the payload, in little endian.
+// A checkpoint is the encoding of the tree with all the segments up to its
+// sequence number applied, the segments are removed once it's written.
+const (
+	segmentExt    = ".log"
+	checkpointExt = ".checkpoint"
+	tempExt       = ".tmp"
+	frameHeader   = 8
+)
+
+type wal struct {
+	dir         string
+	segmentSize int64
+	noSync      bool
+
+	// seq is the sequence number of the segment appended to
+	seq  uint64
+	f    *os.File
+	size int64
+}
+
+func segmentName(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", seq, segmentExt))
+}
+
+func checkpointName(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", seq, checkpointExt))
+}
+
+// listLog returns the sequence numbers of the segments and the checkpoints in dir, in order.
+// Temporary files left by an interrupted checkpoint are removed.
+func listLog(dir string) (segments, checkpoints []uint64, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tempExt) {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		ext := filepath.Ext(name)
+		if ext != segmentExt && ext != checkpointExt {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil {
+			continue
+		}
+		if ext == segmentExt {
+			segments = append(segments, seq)
+		} else {
+			checkpoints = append(checkpoints, seq)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i] < checkpoints[j] })
+	return segments, checkpoints, nil
+}
+
+// replaySegment calls fn with the payload of every frame of the segment. The tail of the last
+// segment may be torn by a crash while it was appended, it's truncated at the first invalid frame.
+// An invalid frame in any other segment is corruption.
+func replaySegment(path string, last bool, fn func([]byte) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	for offset < len(data) {
+		payload, ok := readFrame(data[offset:])
+		if !ok {
+			if !last {
+				return fmt.Errorf("%w: invalid frame at %s:%d", ErrCorrupt, path, offset)
+			}
+			return os.Truncate(path, int64(offset))
+		}
+		if err := fn(payload); err != nil {
+			return err
+		}
+		offset += frameHeader + len(payload)
+	}
+	return nil
+}
+
+func readFrame(data []byte) (payload []byte, ok bool) {
+	if len(data) < frameHeader {
+		return nil, false
+	}
+	length := binary.LittleEndian.Uint32(data[4:])
+	if uint64(len(data)-frameHeader) < uint64(length) {
+		return nil, false
+	}
+	payload = data[frameHeader : frameHeader+int(length)]
+	if crc32.Checksum(data[4:frameHeader+int(length)], castagnoli) != binary.LittleEndian.Uint32(data) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// create starts the segment seq.
+func (w *wal) create(seq uint64) error {
+	f, err := os.OpenFile(segmentName(w.dir, seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := w.sync(nil); err != nil {
+		f.Close()
+		return err
+	}
+	w.seq, w.f, w.size = seq, f, 0
+	return nil
+}
+
+// rotate closes the segment appended to and starts the next one.
+func (w *wal) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	return w.create(w.seq + 1)
+}
+
+// append writes a frame of payload to the log, it's durable when append returns unless noSync is set.
+func (w *wal) append(payload []byte) error {
+	if w.size >= w.segmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	frame := make([]byte, frameHeader, frameHeader+len(payload))
+	binary.LittleEndian.PutUint32(frame[4:], uint32(len(payload)))
+	frame = append(frame, payload...)
+	binary.LittleEndian.PutUint32(frame, crc32.Checksum(frame[4:], castagnoli))
+	n, err := w.f.Write(frame)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return w.sync(w.f)
+}
+
+// sync flushes f to the disk, or the directory of the log if f is nil.
+func (w *wal) sync(f *os.File) error {
+	if w.noSync {
+		return nil
+	}
+	if f != nil {
+		return f.Sync()
+	}
+	dir, err := os.Open(w.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
@@ -0,0 +1,189 @@
+package art
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// EventKind is the kind of an Event.
+type EventKind uint8
+
+const (
+	// EventPut is a value stored for a key
+	EventPut EventKind = iota
+	// EventDelete is a key removed
+	EventDelete
+	// EventOverflow is the last event of a watcher which didn't keep up with the writes,
+	// the events after it are lost. The watcher has to read the prefix and watch it again.
+	EventOverflow
+)
+
+// Event is a change of a key watched by Watch.
+type Event[T any] struct {
+	Kind EventKind
+	Key  Key
+	// Old is the value of the key before the change, if Existed is set
+	Old     T
+	Existed bool
+	// New is the value stored by an EventPut
+	New T
+}
+
+// DefaultWatchBuffer is the number of events buffered by Watch.
+const DefaultWatchBuffer = 1024
+
+type watcher[T any] struct {
+	prefix []byte
+	// closed is set once the channel is closed, the writes skip the watcher from then on
+	closed uint32
+
+	mu     sync.Mutex
+	events chan Event[T]
+}
+
+// Watch returns the events of the writes to the keys starting with prefix, until cancel is called.
+// The events of a key are delivered in the order of its writes, the events of different keys
+// in any order. The events are buffered up to DefaultWatchBuffer, see WatchBuffered.
+func (t *Tree[T]) Watch(prefix []byte) (events <-chan Event[T], cancel func()) {
+	return t.WatchBuffered(prefix, DefaultWatchBuffer)
+}
+
+// WatchBuffered is Watch with a buffer of size events. The writes never wait for the watchers:
+// once the buffer is full, the watcher gets an EventOverflow as its last event and the channel is closed,
+// the writes ignore it from then on.
+//
+// The events are sent while the keys are locked by the writes, while a key is watched the writes go through
+// the same path as Compute, and DeletePrefix sends the deletes before the subtree of the prefix is unlinked.
+// The writes in flight when Watch is called may not be delivered.
+func (t *Tree[T]) WatchBuffered(prefix []byte, size int) (events <-chan Event[T], cancel func()) {
+	w := &watcher[T]{
+		prefix: append([]byte(nil), prefix...),
+		// one more for the overflow event
+		events: make(chan Event[T], size+1),
+	}
+	t.lock.Lock()
+	watchers := append(t.live(), w)
+	t.watchers.Store(&watchers)
+	t.lock.Unlock()
+
+	var once sync.Once
+	return w.events, func() {
+		once.Do(func() {
+			t.lock.Lock()
+			var watchers []*watcher[T]
+			for _, other := range t.live() {
+				if other != w {
+					watchers = append(watchers, other)
+				}
+			}
+			t.watchers.Store(&watchers)
+			t.lock.Unlock()
+			w.close()
+		})
+	}
+}
+
+func (w *watcher[T]) send(e Event[T]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.isClosed() {
+		return
+	}
+	if len(w.events) == cap(w.events)-1 {
+		w.events <- Event[T]{Kind: EventOverflow}
+		atomic.StoreUint32(&w.closed, 1)
+		close(w.events)
+		return
+	}
+	w.events <- e
+}
+
+func (w *watcher[T]) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isClosed() {
+		atomic.StoreUint32(&w.closed, 1)
+		close(w.events)
+	}
+}
+
+func (w *watcher[T]) isClosed() bool {
+	return atomic.LoadUint32(&w.closed) == 1
+}
+
+// list returns the watchers of the tree, the slice is never modified.
+func (t *Tree[T]) list() []*watcher[T] {
+	if watchers := t.watchers.Load(); watchers != nil {
+		return *watchers
+	}
+	return nil
+}
+
+// live returns a copy of the watchers of the tree which aren't closed, an overflowed
+// watcher is dropped by the next change of the watchers.
+func (t *Tree[T]) live() []*watcher[T] {
+	var live []*watcher[T]
+	for _, w := range t.list() {
+		if !w.isClosed() {
+			live = append(live, w)
+		}
+	}
+	return live
+}
+
+// watched reports whether key is watched.
+func (t *Tree[T]) watched(key Key) bool {
+	for _, w := range t.list() {
+		if !w.isClosed() && bytes.HasPrefix(key, w.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedPrefix reports whether a key starting with prefix may be watched.
+func (t *Tree[T]) watchedPrefix(prefix []byte) bool {
+	for _, w := range t.list() {
+		if !w.isClosed() && (bytes.HasPrefix(prefix, w.prefix) || bytes.HasPrefix(w.prefix, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch returns fn notifying the watchers of key of the action it returns. fn is called while
+// the key is locked, before the action is applied.
+func (t *Tree[T]) watch(key Key, fn func(*leaf[T]) (T, Action)) func(*leaf[T]) (T, Action) {
+	return func(old *leaf[T]) (T, Action) {
+		value, action := fn(old)
+		t.notify(key, old, value, action)
+		return value, action
+	}
+}
+
+// notify sends the event of the action applied to key to its watchers.
+func (t *Tree[T]) notify(key Key, old *leaf[T], value T, action Action) {
+	if !t.watched(key) {
+		return
+	}
+	e := Event[T]{Existed: old != nil}
+	if old != nil {
+		e.Old = old.value
+	}
+	switch {
+	case action == Store:
+		e.Kind, e.New = EventPut, value
+	case action == Delete && old != nil:
+		e.Kind = EventDelete
+	default:
+		return
+	}
+	// the key may be reused by the caller once the write returns
+	e.Key = append(Key(nil), key...)
+	for _, w := range t.list() {
+		if !w.isClosed() && bytes.HasPrefix(key, w.prefix) {
+			w.send(e)
+		}
+	}
+}
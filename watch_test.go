@@ -0,0 +1,194 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect returns the events buffered in events.
+func collect[T any](events <-chan Event[T]) (collected []Event[T]) {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return collected
+			}
+			collected = append(collected, e)
+		default:
+			return collected
+		}
+	}
+}
+
+func TestTree_Watch(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("config/a/before"), 0)
+	events, cancel := tree.Watch([]byte("config/a/"))
+	defer cancel()
+
+	tree.Insert(Key("config/a/x"), 1)
+	tree.Insert(Key("config/a/x"), 2)
+	tree.Insert(Key("config/b/x"), 3)
+	tree.Remove(Key("config/a/x"))
+	tree.Remove(Key("config/a/missing"))
+	tree.Compute(Key("config/a/y"), func(int, bool) (int, Action) { return 4, Store })
+	tree.Compute(Key("config/a/y"), func(old int, _ bool) (int, Action) { return old, Keep })
+	tree.ApplyBatch([]Op[int]{{Key: Key("config/a/z"), Value: 5}, {Key: Key("config/a/y"), Delete: true}})
+	assert.Equal(t, 3, tree.DeletePrefix([]byte("config/")))
+	assert.Equal(t, 0, tree.Len())
+
+	assert.Equal(t, []Event[int]{
+		{Kind: EventPut, Key: Key("config/a/x"), New: 1},
+		{Kind: EventPut, Key: Key("config/a/x"), Old: 1, Existed: true, New: 2},
+		{Kind: EventDelete, Key: Key("config/a/x"), Old: 2, Existed: true},
+		{Kind: EventPut, Key: Key("config/a/y"), New: 4},
+		{Kind: EventPut, Key: Key("config/a/z"), New: 5},
+		{Kind: EventDelete, Key: Key("config/a/y"), Old: 4, Existed: true},
+		{Kind: EventDelete, Key: Key("config/a/before"), Old: 0, Existed: true},
+		{Kind: EventDelete, Key: Key("config/a/z"), Old: 5, Existed: true},
+	}, collect(events))
+
+	cancel()
+	cancel()
+	tree.Insert(Key("config/a/x"), 1)
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestTree_WatchOverflow(t *testing.T) {
+	tree := Tree[int]{}
+	events, cancel := tree.WatchBuffered(nil, 2)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		tree.Insert(Key(fmt.Sprint(i)), i)
+	}
+	assert.Equal(t, []Event[int]{
+		{Kind: EventPut, Key: Key("0"), New: 0},
+		{Kind: EventPut, Key: Key("1"), New: 1},
+		{Kind: EventOverflow},
+	}, collect(events))
+	_, ok := <-events
+	assert.False(t, ok)
+	assert.Equal(t, 5, tree.Len())
+
+	// the writes skip the overflowed watcher, and the next change of the watchers drops it
+	assert.False(t, tree.watched(Key("5")))
+	assert.False(t, tree.watchedPrefix(nil))
+	assert.Equal(t, 5, tree.DeletePrefix(nil))
+	_, other := tree.Watch([]byte("other"))
+	other()
+	assert.Empty(t, tree.list())
+}
+
+func TestTree_WatchKeepsNodes(t *testing.T) {
+	tree := Tree[int]{}
+	tree.Insert(Key("a/1"), 1)
+	tree.Insert(Key("b/1"), 1)
+	root := tree.root
+	events, cancel := tree.Watch([]byte("a/"))
+	defer cancel()
+
+	// watching doesn't freeze the nodes like a snapshot, they are still changed in place
+	tree.Insert(Key("b/2"), 2)
+	assert.Same(t, root, tree.root)
+	// the event keeps its own copy of the key
+	key := Key("a/2")
+	tree.Insert(key, 2)
+	key[0] = 'x'
+	assert.Equal(t, []Event[int]{{Kind: EventPut, Key: Key("a/2"), New: 2}}, collect(events))
+}
+
+func TestTree_ConcurrentWatch(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 8
+		writes  = 1000
+	)
+	tree := Tree[int]{}
+	events, cancel := tree.WatchBuffered([]byte("key::"), 2*workers*writes)
+	defer cancel()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= writes; i++ {
+				tree.Insert(Key(fmt.Sprintf("key::%d", w)), i)
+				// the counter is written by all the workers
+				tree.Compute(Key("key::counter"), func(old int, _ bool) (int, Action) { return old + 1, Store })
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	last := map[string]int{}
+	for _, e := range collect(events) {
+		require.Equal(t, EventPut, e.Kind)
+		require.Equal(t, last[string(e.Key)], e.Old)
+		if string(e.Key) == "key::counter" {
+			require.Equal(t, e.Old+1, e.New)
+		} else {
+			require.Equal(t, last[string(e.Key)]+1, e.New)
+		}
+		last[string(e.Key)] = e.New
+	}
+	assert.Equal(t, workers*writes, last["key::counter"])
+	for w := 0; w < workers; w++ {
+		assert.Equal(t, writes, last[fmt.Sprintf("key::%d", w)])
+	}
+}
+
+func TestTree_ConcurrentWatchDeletePrefix(t *testing.T) {
+	t.Parallel()
+	const (
+		workers = 4
+		writes  = 2000
+	)
+	tree := Tree[int]{}
+	events, cancel := tree.WatchBuffered([]byte("key::"), 4*workers*writes)
+	defer cancel()
+	var (
+		wg      sync.WaitGroup
+		removed int64
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= writes; i++ {
+				tree.Insert(Key(fmt.Sprintf("key::%d::%d", w, i%50)), i)
+				if i%100 == 0 {
+					atomic.AddInt64(&removed, int64(tree.DeletePrefix([]byte("key::"))))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// the events of every key are in the order of its writes, replaying them gives the tree
+	current := map[string]int{}
+	deletes := 0
+	for _, e := range collect(events) {
+		old, existed := current[string(e.Key)]
+		require.Equal(t, existed, e.Existed, string(e.Key))
+		require.Equal(t, old, e.Old, string(e.Key))
+		switch e.Kind {
+		case EventPut:
+			current[string(e.Key)] = e.New
+		case EventDelete:
+			delete(current, string(e.Key))
+			deletes++
+		}
+	}
+	assert.Equal(t, int(removed), deletes)
+	assert.Equal(t, len(current), tree.Len())
+	tree.ScanPrefix(nil, func(key Key, value int) bool {
+		assert.Equal(t, current[string(key)], value)
+		return true
+	})
+}